@@ -1,19 +1,65 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"todo-server/pkg/api"
+	"todo-server/pkg/clock"
 	"todo-server/pkg/config"
 	"todo-server/pkg/db"
+	"todo-server/pkg/recurrence"
+
+	// База часовых поясов на случай, если в системе её нет (например, в контейнере)
+	_ "time/tzdata"
 )
 
 func main() {
+	showMigrations := flag.Bool("migrations", false, "показать неприменённые миграции базы данных и выйти")
+	flag.Parse()
+
 	// Загружаем конфигурацию
 	cfg := config.Load()
 
-	// Создаем подключение к БД
-	database, err := db.NewDatabase(cfg.DBFile)
+	if *showMigrations {
+		printPendingMigrations(cfg)
+		return
+	}
+
+	// Часовой пояс, в котором считается "сегодня"
+	location, err := cfg.Location()
+	if err != nil {
+		log.Fatalf("Ошибка настройки часового пояса: %v", err)
+	}
+
+	// Часы сервера: системные или закреплённые для тестов (TODO_NOW, TODO_DEBUG)
+	var clk clock.Clock = clock.System{}
+	if cfg.Now != "" || cfg.Debug {
+		pinned := &clock.Pinned{}
+		if cfg.Now != "" {
+			at, err := clock.Parse(cfg.Now, location)
+			if err != nil {
+				log.Fatalf("Ошибка в TODO_NOW: %v", err)
+			}
+			pinned.Set(at)
+			log.Printf("Часы сервера закреплены на %v", at)
+		}
+		clk = pinned
+	}
+
+	// Создаем подключение к БД: PostgreSQL, если задана строка подключения, хранилище в памяти
+	// при TODO_DBFILE=:memory:, иначе SQLite
+	var database db.Store
+	switch {
+	case cfg.DBDSN != "":
+		database, err = db.NewPostgres(cfg.DBDSN, clk)
+	case cfg.DBFile == db.MemoryFile:
+		database = db.NewMemory(clk)
+		log.Println("Задачи хранятся в памяти и будут потеряны при остановке сервера")
+	default:
+		database, err = db.NewDatabase(cfg.DBFile, clk)
+	}
 	if err != nil {
 		log.Fatalf("Ошибка инициализации базы: %v", err)
 	}
@@ -21,8 +67,19 @@ func main() {
 
 	log.Println("База данных готова к работе")
 
-	// Создаем API с конфигом и БД
-	api := api.NewAPI(database, cfg)
+	// Загружаем производственный календарь
+	calendar, err := recurrence.LoadCalendar(cfg.HolidaysFile)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки календаря: %v", err)
+	}
+
+	// Очистка архива от старых выполненных и удалённых задач
+	if cfg.ArchiveRetention > 0 {
+		go database.RunRetention(cfg.ArchiveRetention)
+	}
+
+	// Создаем API с конфигом, БД, календарём, часовым поясом и часами
+	api := api.NewAPI(database, cfg, calendar, location, clk)
 	router := api.Init()
 
 	// Статический контент
@@ -36,3 +93,31 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// printPendingMigrations выводит миграции, которые будут применены к базе при запуске
+func printPendingMigrations(cfg *config.Config) {
+	var pending []db.Migration
+	var err error
+	source := cfg.DBFile
+	switch {
+	case cfg.DBDSN != "":
+		pending, err = db.PendingPostgresMigrations(cfg.DBDSN)
+		source = "PostgreSQL"
+	case cfg.DBFile == db.MemoryFile:
+		fmt.Println("Хранилище в памяти не использует миграции")
+		return
+	default:
+		pending, err = db.PendingMigrations(cfg.DBFile)
+	}
+	if err != nil {
+		log.Fatalf("Ошибка проверки миграций: %v", err)
+	}
+	if len(pending) == 0 {
+		fmt.Println("Схема базы данных актуальна")
+		return
+	}
+	fmt.Printf("Неприменённые миграции (%s):\n", source)
+	for _, m := range pending {
+		fmt.Printf("%4d  %s\n", m.Version, m.Name)
+	}
+}
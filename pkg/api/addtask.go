@@ -36,7 +36,12 @@ func (a *API) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 2) проверяем и нормализуем дату (и правило повторения)
-	if err := checkDate(&task); err != nil {
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+	if err := a.checkDate(&task, now); err != nil {
 		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
 		return
 	}
@@ -44,7 +49,11 @@ func (a *API) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	// 3) добавляем в БД
 	id, err := a.taskStore.AddTask(&task)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		status := http.StatusInternalServerError
+		if taskProjectError(err) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, errResp{Error: err.Error()})
 		return
 	}
 
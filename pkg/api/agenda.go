@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"time"
+	"todo-server/pkg/db"
+)
+
+// Группы задач повестки дня
+const (
+	groupOverdue  = "overdue"  // просроченные
+	groupToday    = "today"    // на сегодня
+	groupTomorrow = "tomorrow" // на завтра
+	groupWeek     = "week"     // до конца недели
+	groupLater    = "later"    // позже
+)
+
+// agendaGroup - группа задач повестки дня с границами дат (включительно; пустая граница
+// не ограничивает группу). Следующие страницы группы запрашиваются как список
+// с этими from и to (для просроченных - overdue=true) и курсором next_cursor.
+type agendaGroup struct {
+	Name       string     `json:"name"`
+	From       string     `json:"from,omitempty"`
+	To         string     `json:"to,omitempty"`
+	Tasks      []*db.Task `json:"tasks"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Total      int        `json:"total"`
+}
+
+type agendaResp struct {
+	Groups []*agendaGroup `json:"groups"`
+}
+
+// agendaGroups делит даты на группы повестки дня: просроченные, сегодня, завтра,
+// до конца недели (неделя начинается с понедельника) и позже. Группы "до конца недели"
+// нет, если после завтрашнего дня в неделе не осталось дней.
+func agendaGroups(now time.Time) []*agendaGroup {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	date := func(days int) string {
+		return day.AddDate(0, 0, days).Format(DateFormat)
+	}
+
+	// Сколько дней осталось до воскресенья
+	weekLeft := (7 - int(day.Weekday())) % 7
+
+	groups := []*agendaGroup{
+		{Name: groupOverdue, To: date(-1)},
+		{Name: groupToday, From: date(0), To: date(0)},
+		{Name: groupTomorrow, From: date(1), To: date(1)},
+	}
+	if weekLeft >= 2 {
+		groups = append(groups, &agendaGroup{Name: groupWeek, From: date(2), To: date(weekLeft)})
+	}
+	return append(groups, &agendaGroup{Name: groupLater, From: date(max(weekLeft, 1) + 1)})
+}
+
+// writeAgenda отвечает повесткой дня: в каждой группе не больше page.Limit задач,
+// подходящих под приоритеты и теги filter, в порядке page.Sort
+func (a *API) writeAgenda(w http.ResponseWriter, r *http.Request, now time.Time, filter db.TaskFilter, page db.Page) {
+	if page.Cursor != "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "cursor не используется вместе с agenda: следующие страницы группы запрашиваются по её from и to"})
+		return
+	}
+
+	lang := requestLang(r)
+	resp := agendaResp{Groups: agendaGroups(now)}
+	for _, group := range resp.Groups {
+		filter.From, filter.To = group.From, group.To
+		tasks, err := a.taskStore.Tasks(filter, page)
+		if err != nil {
+			writeJSON(w, listErrorStatus(err), errResp{Error: err.Error()})
+			return
+		}
+		describeTasks(lang, tasks.Tasks...)
+		group.Tasks, group.NextCursor, group.Total = tasks.Tasks, tasks.NextCursor, tasks.Total
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
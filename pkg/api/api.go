@@ -2,19 +2,35 @@ package api
 
 import (
 	"net/http"
+	"time"
+	"todo-server/pkg/clock"
 	"todo-server/pkg/config"
 	"todo-server/pkg/db"
+	"todo-server/pkg/recurrence"
 )
 
 type API struct {
 	taskStore db.TaskStore
 	config    *config.Config
+	calendar  *recurrence.Calendar
+	location  *time.Location // часовой пояс по умолчанию для расчёта "сегодня"
+	clock     clock.Clock
 }
 
-func NewAPI(taskStore db.TaskStore, cfg *config.Config) *API {
+func NewAPI(taskStore db.TaskStore, cfg *config.Config, calendar *recurrence.Calendar, location *time.Location,
+	clk clock.Clock) *API {
+	if location == nil {
+		location = time.Local
+	}
+	if clk == nil {
+		clk = clock.System{}
+	}
 	return &API{
 		taskStore: taskStore,
 		config:    cfg,
+		calendar:  calendar,
+		location:  location,
+		clock:     clk,
 	}
 }
 
@@ -22,10 +38,27 @@ func (a *API) Init() *http.ServeMux {
 	router := http.NewServeMux()
 
 	router.HandleFunc("/api/nextdate", a.nextDayHandler)
+	router.HandleFunc("/api/nextdates", a.nextDatesHandler)
 	router.HandleFunc("/api/task", a.authMiddleware(a.taskHandler))
 	router.HandleFunc("/api/tasks", a.authMiddleware(a.tasksHandler))
 	router.HandleFunc("/api/task/done", a.authMiddleware(a.doneTaskHandler))
+	router.HandleFunc("/api/task/skip", a.authMiddleware(a.skipTaskHandler))
+	router.HandleFunc("/api/task/exdate", a.authMiddleware(a.exdateHandler))
+	router.HandleFunc("/api/task/restore", a.authMiddleware(a.restoreTaskHandler))
+	router.HandleFunc("/api/completions", a.authMiddleware(a.completionsHandler))
+	router.HandleFunc("/api/tags", a.authMiddleware(a.tagsHandler))
+	router.HandleFunc("/api/tag", a.authMiddleware(a.tagHandler))
+	router.HandleFunc("/api/tag/merge", a.authMiddleware(a.mergeTagHandler))
+	router.HandleFunc("/api/projects", a.authMiddleware(a.projectsHandler))
+	router.HandleFunc("/api/project", a.authMiddleware(a.projectHandler))
+	router.HandleFunc("/api/project/archive", a.authMiddleware(a.archiveProjectHandler))
+	router.HandleFunc("/api/task/move", a.authMiddleware(a.moveTaskHandler))
+	router.HandleFunc("/api/undo", a.authMiddleware(a.undoHandler))
 	router.HandleFunc("/api/signin", a.signinHandler)
 
+	if a.config.Debug {
+		router.HandleFunc("/api/debug/now", a.authMiddleware(a.debugNowHandler))
+	}
+
 	return router
 }
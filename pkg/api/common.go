@@ -5,10 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 	"todo-server/pkg/db"
+	"todo-server/pkg/recurrence"
+)
+
+// Формат времени начала задачи
+const TimeFormat = "15:04"
+
+// Максимальная длительность задачи в минутах - сутки
+const maxDuration = 24 * 60
+
+// Режимы отсчёта повторений задачи
+const (
+	repeatModeSchedule   = "schedule"
+	repeatModeCompletion = "completion"
 )
 
 type errResp struct {
@@ -19,8 +33,14 @@ type idResp struct {
 	ID string `json:"id"`
 }
 
+type datesResp struct {
+	Dates []string `json:"dates"`
+}
+
 type TasksResp struct {
-	Tasks []*db.Task `json:"tasks"`
+	Tasks      []*db.Task `json:"tasks"`
+	NextCursor string     `json:"next_cursor,omitempty"` // курсор следующей страницы, если она есть
+	Total      int        `json:"total"`                 // сколько всего задач в списке
 }
 
 func afterNow(date, now time.Time) bool {
@@ -39,8 +59,9 @@ func isToday(date, now time.Time) bool {
 }
 
 // проверка и нормализация даты согласно условиям
-func checkDate(task *db.Task) error {
-	now := time.Now()
+func (a *API) checkDate(task *db.Task, now time.Time) error {
+	// Даты задач сравниваем как календарные дни в часовом поясе пользователя
+	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
 	// если дата пустая или в прошлом — используем сегодняшнюю
 	if strings.TrimSpace(task.Date) == "" {
@@ -53,19 +74,75 @@ func checkDate(task *db.Task) error {
 		return fmt.Errorf("некорректный формат даты (ожидается %s)", DateFormat)
 	}
 
+	if err := checkTime(task); err != nil {
+		return err
+	}
+	if task.Priority < 0 || task.Priority > db.MaxPriority {
+		return fmt.Errorf("приоритет должен быть от 0 до %d", db.MaxPriority)
+	}
+	if task.Tags, err = db.NormalizeTags(task.Tags); err != nil {
+		return err
+	}
+
 	repeat := strings.TrimSpace(task.Repeat)
+	if repeat == "" && (task.RepeatCount != 0 || task.RepeatUntil != "") {
+		return errors.New("условия окончания повторений указываются только вместе с правилом повторения")
+	}
+	if repeat == "" && len(task.Exdates) > 0 {
+		return errors.New("даты-исключения указываются только вместе с правилом повторения")
+	}
+	if repeat == "" && task.RepeatMode != "" {
+		return errors.New("режим повторения указывается только вместе с правилом повторения")
+	}
+	if repeat == "" {
+		task.RepeatStart = ""
+	}
+
 	if repeat != "" {
+		// правило проверяем всегда, даже если дата в будущем
+		rule, err := recurrence.Parse(repeat)
+		if err != nil {
+			return err
+		}
+		if err := checkEnd(task, rule); err != nil {
+			return err
+		}
+		if task.Exdates, err = normalizeExdates(task.Exdates); err != nil {
+			return err
+		}
+
+		// Серия начинается с указанной даты задачи, если начало не передано явно
+		task.RepeatStart = strings.TrimSpace(task.RepeatStart)
+		if task.RepeatStart == "" {
+			task.RepeatStart = task.Date
+		}
+		if _, err := time.Parse(DateFormat, task.RepeatStart); err != nil {
+			return fmt.Errorf("некорректный формат repeat_start (ожидается %s)", DateFormat)
+		}
+
+		switch task.RepeatMode {
+		case "":
+			task.RepeatMode = repeatModeSchedule
+		case repeatModeSchedule, repeatModeCompletion:
+		default:
+			return fmt.Errorf("repeat_mode должен быть %s или %s", repeatModeSchedule, repeatModeCompletion)
+		}
+
 		// Для повторяющихся задач с интервалом "d 1" и сегодняшней датой
 		// оставляем сегодняшнюю дату как дату первого выполнения
 		if repeat == "d 1" || isToday(t, now) {
 			// Оставляем сегодняшнюю дату
 		} else if !afterNow(t, now) {
 			// Для других случаев, если дата в прошлом, рассчитываем следующую
-			next, err := NextDate(now, task.Date, repeat)
+			series, err := a.taskSeries(task)
+			if err != nil {
+				return err
+			}
+			next, err := series.Next(now)
 			if err != nil {
 				return fmt.Errorf("ошибка расчета следующей даты: %v", err)
 			}
-			task.Date = next
+			task.Date = next.Format(DateFormat)
 		}
 	} else {
 		// правила нет — если дата в прошлом/сегодня, ставим сегодня
@@ -77,150 +154,127 @@ func checkDate(task *db.Task) error {
 	return nil
 }
 
-// Вспомогательная функция для преобразования дня недели
-func convertWeekdayToTargetFormat(wd time.Weekday) int {
-	// Go: Sunday=0, Monday=1, Tuesday=2, Wednesday=3, Thursday=4, Friday=5, Saturday=6
-	// Нам нужно: Monday=1, Tuesday=2, Wednesday=3, Thursday=4, Friday=5, Saturday=6, Sunday=7
-
-	if wd == time.Sunday {
-		return 7
-	}
-	return int(wd) // Monday=1, Tuesday=2, etc.
-}
-
-func NextDate(now time.Time, dstart string, repeat string) (string, error) {
-	if repeat == "" {
-		return "", errors.New("не указано правило повторения")
+// checkTime проверяет и нормализует время начала и длительность задачи.
+// Время сохраняется при переносе повторяющейся задачи на следующую дату.
+func checkTime(task *db.Task) error {
+	task.StartTime = strings.TrimSpace(task.StartTime)
+	if task.StartTime == "" {
+		if task.Duration != 0 {
+			return errors.New("длительность указывается только вместе со временем начала")
+		}
+		return nil
 	}
 
-	date, err := time.Parse(DateFormat, dstart)
+	t, err := time.Parse(TimeFormat, task.StartTime)
 	if err != nil {
-		return "", fmt.Errorf("не удалось распарсить dstart: %v", err)
+		return fmt.Errorf("некорректный формат времени (ожидается %s)", TimeFormat)
 	}
+	task.StartTime = t.Format(TimeFormat)
 
-	parts := strings.Fields(repeat)
-	if len(parts) == 0 {
-		return "", errors.New("неверный формат repeat")
+	if task.Duration < 0 || task.Duration > maxDuration {
+		return fmt.Errorf("длительность должна быть от 0 до %d минут", maxDuration)
 	}
 
-	switch parts[0] {
-	case "d":
-		if len(parts) != 2 {
-			return "", errors.New("для правила d нужно указать интервал в днях")
-		}
-		days, err := strconv.Atoi(parts[1])
-		if err != nil || days < 1 || days > 400 {
-			return "", errors.New("недопустимый интервал для d")
-		}
-		for {
-			date = date.AddDate(0, 0, days)
-			if afterNow(date, now) {
-				break
-			}
-		}
+	return nil
+}
 
-	case "y":
-		for {
-			date = date.AddDate(1, 0, 0)
-			if afterNow(date, now) {
-				break
-			}
-		}
+// checkEnd проверяет условия окончания повторений задачи; если они не указаны явно,
+// берутся из COUNT и UNTIL правила RRULE
+func checkEnd(task *db.Task, rule recurrence.Rule) error {
+	count, until := recurrence.Bounds(rule)
 
-	case "w":
-		if !afterNow(date, now) {
-			date = now
-		}
+	if task.RepeatCount < 0 {
+		return errors.New("repeat_count не может быть отрицательным")
+	}
+	if task.RepeatCount == 0 {
+		task.RepeatCount = count
+	}
 
-		if len(parts) != 2 {
-			return "", errors.New("для правила w нужно указать дни недели через запятую")
+	task.RepeatUntil = strings.TrimSpace(task.RepeatUntil)
+	if task.RepeatUntil == "" && !until.IsZero() {
+		task.RepeatUntil = until.Format(DateFormat)
+	}
+	if task.RepeatUntil != "" {
+		if _, err := time.Parse(DateFormat, task.RepeatUntil); err != nil {
+			return fmt.Errorf("некорректный формат repeat_until (ожидается %s)", DateFormat)
 		}
+	}
 
-		daysStr := strings.Split(parts[1], ",")
-		targetDays := make(map[int]bool)
-		for _, s := range daysStr {
-			n, err := strconv.Atoi(s)
-			if err != nil || n < 1 || n > 7 {
-				return "", fmt.Errorf("день недели должен быть от 1 до 7: %s", s)
-			}
-			targetDays[n] = true
-		}
+	return nil
+}
 
-		current := date
-		// Ищем в течение 400 дней
-		for day := 0; day < 400; day++ {
-			current = current.AddDate(0, 0, 1)
+// normalizeExdates проверяет формат дат-исключений, убирает повторы и сортирует их
+func normalizeExdates(exdates []string) ([]string, error) {
+	seen := make(map[string]bool, len(exdates))
+	res := make([]string, 0, len(exdates))
+	for _, d := range exdates {
+		d = strings.TrimSpace(d)
+		if _, err := time.Parse(DateFormat, d); err != nil {
+			return nil, fmt.Errorf("некорректная дата-исключение %q (ожидается %s)", d, DateFormat)
+		}
+		if !seen[d] {
+			seen[d] = true
+			res = append(res, d)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
 
-			// Правильное преобразование дня недели
-			weekday := convertWeekdayToTargetFormat(current.Weekday())
+// taskSeries собирает серию повторений задачи
+func (a *API) taskSeries(task *db.Task) (recurrence.Series, error) {
+	rule, err := recurrence.Parse(task.Repeat)
+	if err != nil {
+		return recurrence.Series{}, err
+	}
 
-			if targetDays[weekday] && afterNow(current, now) {
-				return current.Format(DateFormat), nil
-			}
-		}
-		return "", errors.New("не найдена подходящая дата")
+	start, err := time.Parse(DateFormat, task.Date)
+	if err != nil {
+		return recurrence.Series{}, fmt.Errorf("некорректная дата задачи: %v", err)
+	}
 
-	case "m":
-		if len(parts) < 2 {
-			return "", errors.New("для правила m нужно указать дни месяца")
+	series := recurrence.Series{
+		Rule:     rule,
+		Start:    start,
+		Date:     start,
+		End:      recurrence.End{Count: task.RepeatCount, Done: task.DoneCount},
+		Calendar: a.calendar,
+	}
+	if task.RepeatMode == repeatModeCompletion {
+		series.Anchor = recurrence.AnchorCompletion
+	}
+	if task.RepeatStart != "" {
+		series.Start, err = time.Parse(DateFormat, task.RepeatStart)
+		if err != nil {
+			return recurrence.Series{}, fmt.Errorf("некорректная дата repeat_start: %v", err)
 		}
-
-		dayStrs := strings.Split(parts[1], ",")
-		targetDays := make(map[int]bool)
-		for _, s := range dayStrs {
-			n, err := strconv.Atoi(s)
-			if err != nil || n < -2 || n > 31 || n == 0 {
-				return "", fmt.Errorf("день месяца должен быть от 1 до 31 или -1, -2: %s", s)
-			}
-			targetDays[n] = true
+	}
+	if task.RepeatUntil != "" {
+		series.End.Until, err = time.Parse(DateFormat, task.RepeatUntil)
+		if err != nil {
+			return recurrence.Series{}, fmt.Errorf("некорректная дата repeat_until: %v", err)
 		}
-
-		targetMonths := make(map[int]bool)
-		if len(parts) >= 3 {
-			monthStrs := strings.Split(parts[2], ",")
-			for _, s := range monthStrs {
-				m, err := strconv.Atoi(s)
-				if err != nil || m < 1 || m > 12 {
-					return "", fmt.Errorf("месяц должен быть от 1 до 12: %s", s)
-				}
-				targetMonths[m] = true
-			}
-		} else {
-			for m := 1; m <= 12; m++ {
-				targetMonths[m] = true
-			}
+	}
+	for _, d := range task.Exdates {
+		exdate, err := time.Parse(DateFormat, d)
+		if err != nil {
+			return recurrence.Series{}, fmt.Errorf("некорректная дата-исключение: %v", err)
 		}
+		series.Except = append(series.Except, exdate)
+	}
 
-		// Начинаем поиск с следующего дня от исходной даты
-		current := date.AddDate(0, 0, 1)
-
-		// Ищем в течение 730 дней (2 года)
-		for day := 0; day < 730; day++ {
-			currentMonth := int(current.Month())
-			currentDay := current.Day()
-			lastDay := time.Date(current.Year(), current.Month()+1, 0, 0, 0, 0, 0, current.Location()).Day()
-
-			// Проверяем соответствие дню
-			dayMatch := targetDays[currentDay] ||
-				(targetDays[-1] && currentDay == lastDay) ||
-				(targetDays[-2] && currentDay == lastDay-1)
-
-			// Проверяем соответствие месяцу
-			monthMatch := targetMonths[currentMonth]
-
-			if dayMatch && monthMatch && afterNow(current, now) {
-				return current.Format(DateFormat), nil
-			}
-
-			current = current.AddDate(0, 0, 1)
-		}
-		return "", errors.New("не найдена подходящая дата")
+	return series, nil
+}
 
-	default:
-		return "", fmt.Errorf("неподдерживаемый формат repeat: %s", repeat)
-	}
+// NextDate вычисляет следующую дату задачи по правилу повторения
+func NextDate(now time.Time, dstart string, repeat string) (string, error) {
+	return recurrence.NextDate(now, dstart, repeat)
+}
 
-	return date.Format(DateFormat), nil
+// NextDates вычисляет до n следующих дат задачи по правилу повторения с учётом
+// производственного календаря сервера (TODO_HOLIDAYS)
+func (a *API) NextDates(now time.Time, dstart string, repeat string, n int) ([]string, error) {
+	return recurrence.NextDatesIn(a.calendar, now, dstart, repeat, n)
 }
 
 func checkSearchDate(date string) bool {
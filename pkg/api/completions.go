@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"todo-server/pkg/db"
+)
+
+type completionsResp struct {
+	Completions []*db.Completion `json:"completions"`
+}
+
+// completionsHandler - история выполнений задачи (?id=...) или всех задач, начиная с последних
+func (a *API) completionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	completions, err := a.taskStore.Completions(r.URL.Query().Get("id"), 50)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, completionsResp{Completions: completions})
+}
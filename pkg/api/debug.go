@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"time"
+	"todo-server/pkg/clock"
+)
+
+type nowResp struct {
+	Now    string `json:"now"`
+	Pinned bool   `json:"pinned"`
+}
+
+// debugNowHandler - отладочное управление часами сервера (только при TODO_DEBUG):
+// GET показывает текущее время, POST ?now=20060102 закрепляет его, DELETE возвращает системное
+func (a *API) debugNowHandler(w http.ResponseWriter, r *http.Request) {
+	pinned, ok := a.clock.(*clock.Pinned)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errResp{Error: "часы сервера нельзя закрепить"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		at, err := clock.Parse(r.URL.Query().Get("now"), a.location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+			return
+		}
+		pinned.Set(at)
+	case http.MethodDelete:
+		pinned.Reset()
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nowResp{Now: a.clock.Now().In(a.location).Format(time.RFC3339), Pinned: pinned.IsSet()})
+}
@@ -16,7 +16,7 @@ func (a *API) deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := a.taskStore.DeleteTask(id)
+	err := a.taskStore.DeleteTask(id, a.clock.Now())
 	if err != nil {
 		writeJSON(w, http.StatusNotFound, errResp{Error: err.Error()})
 		return
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"todo-server/pkg/db"
+	"todo-server/pkg/recurrence"
+)
+
+// requestLang выбирает язык описаний по заголовку Accept-Language: поддерживаемый язык
+// с наибольшим весом q (при равном весе - первый в списке), по умолчанию русский
+func requestLang(r *http.Request) string {
+	lang, best := recurrence.LangRu, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+		primary = strings.ToLower(primary)
+		if primary != recurrence.LangRu && primary != recurrence.LangEn {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || v < 0 || v > 1 {
+					v = 0
+				}
+				q = v
+			}
+		}
+		// q=0 - язык не подходит клиенту
+		if q > best {
+			lang, best = primary, q
+		}
+	}
+	return lang
+}
+
+// describeTasks заполняет описание правила повторения у задач
+func describeTasks(lang string, tasks ...*db.Task) {
+	for _, task := range tasks {
+		if task.Repeat == "" {
+			continue
+		}
+		rule, err := recurrence.Parse(task.Repeat)
+		if err != nil {
+			continue
+		}
+		task.RepeatText = recurrence.Describe(rule, lang)
+	}
+}
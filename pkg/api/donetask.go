@@ -1,10 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
+	"todo-server/pkg/recurrence"
 )
 
 func (a *API) doneTaskHandler(w http.ResponseWriter, r *http.Request) {
@@ -19,6 +21,12 @@ func (a *API) doneTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+
 	// Получаем задачу
 	task, err := a.taskStore.GetTask(id)
 	if err != nil {
@@ -26,33 +34,42 @@ func (a *API) doneTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Если задача не повторяющаяся - удаляем
-	if strings.TrimSpace(task.Repeat) == "" {
-		err = a.taskStore.DeleteTask(id)
-		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
-			return
-		}
-	} else {
-		// Если задача повторяющаяся - рассчитываем следующую дату
-		now := time.Now()
-
+	// Для повторяющейся задачи рассчитываем следующую дату
+	var nextDate string
+	repeatStart := task.RepeatStart
+	if strings.TrimSpace(task.Repeat) != "" {
 		// Используем только дату без времени
 		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-		nextDate, err := NextDate(today, task.Date, task.Repeat)
+		series, err := a.taskSeries(task)
 		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, errResp{Error: fmt.Sprintf("Ошибка расчета следующей даты: %v", err)})
+			writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
 			return
 		}
 
-		// Обновляем дату задачи
-		err = a.taskStore.UpdateDate(id, nextDate)
-		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		next, err := series.Done(today)
+		switch {
+		case err == nil:
+			nextDate = next.Format(DateFormat)
+			// При отсчёте от выполнения серия начинается заново с сегодняшнего дня, а если
+			// дату задачи перенесли вне сетки серии - с этой даты
+			repeatStart = series.Origin().Format(DateFormat)
+			if series.Anchor == recurrence.AnchorCompletion {
+				repeatStart = today.Format(DateFormat)
+			}
+		case !errors.Is(err, recurrence.ErrFinished):
+			writeJSON(w, http.StatusInternalServerError, errResp{Error: fmt.Sprintf("Ошибка расчета следующей даты: %v", err)})
 			return
 		}
 	}
 
+	// Записываем выполнение в историю; если задача не повторяющаяся или серия
+	// повторений завершена - удаляем её, иначе переносим на следующую дату
+	err = a.taskStore.CompleteTask(id, nextDate, repeatStart, now)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		return
+	}
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{})
 }
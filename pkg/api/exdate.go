@@ -0,0 +1,82 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exdateHandler - добавление (POST) и удаление (DELETE) даты-исключения повторяющейся задачи
+func (a *API) exdateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор"})
+		return
+	}
+
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	excluded, err := time.Parse(DateFormat, date)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("некорректный формат даты (ожидается %s)", DateFormat)})
+		return
+	}
+
+	task, err := a.taskStore.GetTask(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errResp{Error: err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(task.Repeat) == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "даты-исключения указываются только для повторяющихся задач"})
+		return
+	}
+
+	var exdates []string
+	if r.Method == http.MethodPost {
+		exdates = append(task.Exdates, date)
+	} else {
+		for _, d := range task.Exdates {
+			if d != date {
+				exdates = append(exdates, d)
+			}
+		}
+	}
+
+	exdates, err = normalizeExdates(exdates)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+
+	// Если исключена текущая дата задачи, задача переносится на следующее повторение,
+	// как при пропуске: исключение и перенос записываются одной операцией
+	if r.Method == http.MethodPost && date == task.Date {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		after := today
+		if date > today.Format(DateFormat) {
+			after = time.Date(excluded.Year(), excluded.Month(), excluded.Day(), 0, 0, 0, 0, now.Location())
+		}
+		err = a.skipTo(task, exdates, after, now)
+	} else {
+		err = a.taskStore.UpdateExdates(id, exdates)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
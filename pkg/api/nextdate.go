@@ -3,10 +3,18 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
+	"todo-server/pkg/recurrence"
 )
 
-const DateFormat = "20060102"
+const DateFormat = recurrence.DateFormat
+
+// Количество дат в предпросмотре правила: по умолчанию и максимальное
+const (
+	defaultPreviewDates = 10
+	maxPreviewDates     = 100
+)
 
 func (a *API) nextDayHandler(w http.ResponseWriter, r *http.Request) {
 	nowStr := r.FormValue("now")
@@ -18,11 +26,12 @@ func (a *API) nextDayHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var now time.Time
-	var err error
-	if nowStr == "" {
-		now = time.Now()
-	} else {
+	now, err := a.now(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if nowStr != "" {
 		now, err = time.Parse(DateFormat, nowStr)
 		if err != nil {
 			http.Error(w, "Неверный формат параметра now", http.StatusBadRequest)
@@ -30,7 +39,7 @@ func (a *API) nextDayHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	next, err := NextDate(now, dateStr, repeat)
+	next, err := recurrence.NextDateIn(a.calendar, now, dateStr, repeat)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -38,3 +47,51 @@ func (a *API) nextDayHandler(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Fprint(w, next)
 }
+
+// nextDatesHandler - предпросмотр: JSON-список ближайших n дат правила повторения
+func (a *API) nextDatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	nowStr := r.FormValue("now")
+	dateStr := r.FormValue("date")
+	repeat := r.FormValue("repeat")
+
+	if dateStr == "" || repeat == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Параметры date и repeat обязательны"})
+		return
+	}
+
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+	if nowStr != "" {
+		now, err = time.Parse(DateFormat, nowStr)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: "Неверный формат параметра now"})
+			return
+		}
+	}
+
+	n := defaultPreviewDates
+	if nStr := r.FormValue("n"); nStr != "" {
+		var err error
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n < 1 || n > maxPreviewDates {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("Параметр n должен быть от 1 до %d", maxPreviewDates)})
+			return
+		}
+	}
+
+	dates, err := a.NextDates(now, dateStr, repeat, n)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, datesResp{Dates: dates})
+}
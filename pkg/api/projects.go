@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"todo-server/pkg/db"
+)
+
+type projectsResp struct {
+	Projects []*db.Project `json:"projects"`
+}
+
+// projectsHandler возвращает активные проекты или, с archived=true, проекты из архива
+func (a *API) projectsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	projects, err := a.taskStore.Projects(r.URL.Query().Get("archived") == "true")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, projectsResp{Projects: projects})
+}
+
+// projectHandler - создание (POST), изменение (PUT) и удаление (DELETE) проекта
+func (a *API) projectHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("read body error: %v", err)})
+			return
+		}
+		defer r.Body.Close()
+
+		var project db.Project
+		if err = json.Unmarshal(body, &project); err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("json decode error: %v", err)})
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			id, err := a.taskStore.AddProject(&project)
+			if err != nil {
+				writeJSON(w, projectErrorStatus(err), errResp{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, idResp{ID: strconv.FormatInt(id, 10)})
+			return
+		}
+
+		if project.ID == "" {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор проекта"})
+			return
+		}
+		if err := a.taskStore.UpdateProject(&project); err != nil {
+			writeJSON(w, projectErrorStatus(err), errResp{Error: err.Error()})
+			return
+		}
+
+	case http.MethodDelete:
+		// Задачи удаляемого проекта либо удаляются вместе с ним (cascade=true),
+		// либо переносятся в другой проект (move_to=3 или move_to=none). Удаление
+		// вместе с задачами отменяется через /api/undo.
+		values := r.URL.Query()
+		id := values.Get("id")
+		if id == "" {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор"})
+			return
+		}
+		cascade := values.Get("cascade") == "true"
+		moveTo := values.Get("move_to")
+		if cascade == (moveTo != "") {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: "Укажите cascade=true или move_to"})
+			return
+		}
+		if err := a.taskStore.DeleteProject(id, cascade, moveTo, a.clock.Now()); err != nil {
+			writeJSON(w, projectErrorStatus(err), errResp{Error: err.Error()})
+			return
+		}
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// archiveProjectHandler переносит проект в архив (POST) или возвращает его из архива (DELETE)
+func (a *API) archiveProjectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор"})
+		return
+	}
+
+	var err error
+	if r.Method == http.MethodPost {
+		err = a.taskStore.ArchiveProject(id, a.clock.Now())
+	} else {
+		err = a.taskStore.UnarchiveProject(id)
+	}
+	if err != nil {
+		writeJSON(w, projectErrorStatus(err), errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// moveTaskHandler переносит задачу id в проект project (project=none - убирает из проекта)
+func (a *API) moveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	id, project := r.URL.Query().Get("id"), r.URL.Query().Get("project")
+	if id == "" || project == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указаны идентификаторы задачи id и проекта project"})
+		return
+	}
+
+	if err := a.taskStore.MoveTask(id, project); err != nil {
+		status := http.StatusNotFound
+		if taskProjectError(err) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// projectErrorStatus возвращает код ответа для ошибки операции с проектом. Кроме отсутствующего
+// проекта и занятого названия, хранилище возвращает ошибки в названии, цвете и идентификаторе.
+func projectErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrProjectNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrProjectExists):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// taskProjectError сообщает, что задачу нельзя поместить в указанный проект
+func taskProjectError(err error) bool {
+	return errors.Is(err, db.ErrProjectNotFound) || errors.Is(err, db.ErrProjectArchived) ||
+		errors.Is(err, db.ErrInvalidProject)
+}
+
+// validProject проверяет идентификатор проекта в параметре запроса
+func validProject(s string) bool {
+	if s == db.NoProject {
+		return true
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && n > 0
+}
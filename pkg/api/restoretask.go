@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+)
+
+// restoreTaskHandler возвращает выполненную или удалённую задачу из архива
+func (a *API) restoreTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор"})
+		return
+	}
+
+	if err := a.taskStore.RestoreTask(id); err != nil {
+		writeJSON(w, http.StatusNotFound, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
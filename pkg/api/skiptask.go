@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"todo-server/pkg/db"
+	"todo-server/pkg/recurrence"
+)
+
+// skipTaskHandler - пропуск текущего повторения задачи: в отличие от выполнения
+// задача переносится на следующую дату без увеличения счётчика выполнений
+func (a *API) skipTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор"})
+		return
+	}
+
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+
+	task, err := a.taskStore.GetTask(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errResp{Error: err.Error()})
+		return
+	}
+
+	if strings.TrimSpace(task.Repeat) == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "пропустить можно только повторяющуюся задачу"})
+		return
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if err := a.skipTo(task, task.Exdates, today, now); err != nil {
+		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// skipTo заменяет даты-исключения задачи на exdates и переносит её на первое повторение
+// после after без увеличения счётчика выполнений. Если повторений больше нет, серия
+// завершена и задача уходит в архив как выполненная.
+func (a *API) skipTo(task *db.Task, exdates []string, after, now time.Time) error {
+	skipped := *task
+	skipped.Exdates = exdates
+	series, err := a.taskSeries(&skipped)
+	if err != nil {
+		return err
+	}
+
+	next, err := series.Next(after)
+	switch {
+	case errors.Is(err, recurrence.ErrFinished):
+		// Пропущено последнее повторение серии
+		return a.taskStore.SkipTask(task.ID, exdates, "", "", now)
+	case err != nil:
+		return fmt.Errorf("Ошибка расчета следующей даты: %v", err)
+	}
+	return a.taskStore.SkipTask(task.ID, exdates, next.Format(DateFormat), series.Origin().Format(DateFormat), now)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo-server/pkg/db"
+)
+
+type tagsResp struct {
+	Tags []*db.Tag `json:"tags"`
+}
+
+// tagsHandler возвращает все теги с числом активных задач у каждого
+func (a *API) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	tags, err := a.taskStore.Tags()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tagsResp{Tags: tags})
+}
+
+// tagHandler - создание (POST), переименование (PUT) и удаление (DELETE) тега
+func (a *API) tagHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("read body error: %v", err)})
+			return
+		}
+		defer r.Body.Close()
+
+		var tag db.Tag
+		if err = json.Unmarshal(body, &tag); err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("json decode error: %v", err)})
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			id, err := a.taskStore.AddTag(tag.Name)
+			if err != nil {
+				writeJSON(w, tagErrorStatus(err), errResp{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, idResp{ID: strconv.FormatInt(id, 10)})
+			return
+		}
+
+		if tag.ID == "" {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор тега"})
+			return
+		}
+		// Задачи ссылаются на тег, поэтому новое имя сразу появляется у всех задач
+		if err := a.taskStore.RenameTag(tag.ID, tag.Name); err != nil {
+			writeJSON(w, tagErrorStatus(err), errResp{Error: err.Error()})
+			return
+		}
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указан идентификатор"})
+			return
+		}
+		if err := a.taskStore.DeleteTag(id); err != nil {
+			writeJSON(w, tagErrorStatus(err), errResp{Error: err.Error()})
+			return
+		}
+
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// mergeTagHandler объединяет тег id с тегом into: задачи с тегом id получают тег into,
+// а тег id удаляется
+func (a *API) mergeTagHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	id, into := r.URL.Query().Get("id"), r.URL.Query().Get("into")
+	if id == "" || into == "" {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "Не указаны идентификаторы тегов id и into"})
+		return
+	}
+
+	if err := a.taskStore.MergeTags(id, into); err != nil {
+		writeJSON(w, tagErrorStatus(err), errResp{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// tagErrorStatus возвращает код ответа для ошибки операции с тегом. Кроме отсутствующего
+// тега и занятого имени, хранилище возвращает ошибки в имени и идентификаторе тега.
+func tagErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrTagNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrTagExists):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// parseTags разбирает список тегов через запятую
+func parseTags(s string) ([]string, error) {
+	return db.NormalizeTags(strings.Split(s, ","))
+}
@@ -1,49 +1,202 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 	"todo-server/pkg/db"
+	"todo-server/pkg/query"
 )
 
+// Наибольший размер страницы списка задач
+const maxPageLimit = 500
+
 func (a *API) tasksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
 		return
 	}
 
-	search := r.URL.Query().Get("search")
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+	today := now.Format(DateFormat)
+
+	values := r.URL.Query()
+	search := values.Get("search")
+	archived := values.Get("archived") == "true"
+
+	// Страница списка: limit задач после курсора из next_cursor предыдущей страницы
+	// в порядке sort (date, priority или title)
+	page := db.Page{Limit: db.DefaultPageLimit, Cursor: values.Get("cursor"), Sort: values.Get("sort")}
+	if limitStr := values.Get("limit"); limitStr != "" {
+		page.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || page.Limit < 1 || page.Limit > maxPageLimit {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("Параметр limit должен быть от 1 до %d", maxPageLimit)})
+			return
+		}
+	}
+
+	// Отбор по приоритетам: priority=2 или priority=2,3
+	var priorities []int
+	if s := values.Get("priority"); s != "" {
+		if priorities, err = parsePriorities(s); err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+			return
+		}
+	}
+	// Отбор по тегам: tags=дом,дача - задачи с одним из тегов, с tags_mode=all - со всеми
+	filter := db.TaskFilter{Priorities: priorities}
+	if s := values.Get("tags"); s != "" {
+		if filter.Tags, err = parseTags(s); err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+			return
+		}
+	}
+	switch values.Get("tags_mode") {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "tags_mode должен быть any или all"})
+		return
+	}
+	// Отбор по проекту: project=3 или project=none - задачи без проекта. Без параметра
+	// задачи проектов из архива не показываются.
+	if filter.Project = values.Get("project"); filter.Project != "" && !validProject(filter.Project) {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: db.ErrInvalidProject.Error()})
+		return
+	}
+	if archived && (page.Sort != "" || priorities != nil || filter.Tags != nil || filter.Project != "") {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "параметры sort, priority, tags и project не применяются к архиву"})
+		return
+	}
+
+	// Диапазон дат, просроченные задачи и повестка дня - виды списка активных задач
+	listed := values.Get("from") != "" || values.Get("to") != "" ||
+		values.Get("overdue") == "true" || values.Get("agenda") == "true"
+	if listed && (search != "" || archived) {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: "параметры from, to, overdue и agenda не сочетаются с search и archived"})
+		return
+	}
+	if values.Get("agenda") == "true" {
+		a.writeAgenda(w, r, now, filter, page)
+		return
+	}
 
-	var tasks []*db.Task
-	var err error
+	var tasks *db.TaskPage
 
-	if search != "" {
+	if archived {
+		// Выполненные и удалённые задачи
+		tasks, err = a.taskStore.ArchivedTasks(page)
+	} else if search != "" {
 		// Проверяем является ли поиск датой
 		if checkSearchDate(search) {
 			// Преобразуем дату в формат БД
-			dbDate, err := convertSearchDateToDBFormat(search)
+			var dbDate string
+			dbDate, err = convertSearchDateToDBFormat(search)
 			if err != nil {
 				writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
 				return
 			}
 			// Ищем задачи по дате
-			tasks, err = a.taskStore.SearchTasksByDate(dbDate, 50)
+			filter.From, filter.To = dbDate, dbDate
+			tasks, err = a.taskStore.Tasks(filter, page)
 		} else {
-			// Ищем задачи по тексту
-			tasks, err = a.taskStore.SearchTasksByText(search, 50)
+			// Ищем задачи по запросу: слова, фразы и условия вида from:01.11.2026
+			var q *query.Query
+			if q, err = query.Parse(search); err == nil {
+				if priorities != nil {
+					q.Nodes = append(q.Nodes, &query.PriorityCond{Priorities: priorities})
+				}
+				// Условие tag: с несколькими тегами отбирает задачи с одним из них
+				if filter.AllTags {
+					for _, tag := range filter.Tags {
+						q.Nodes = append(q.Nodes, &query.TagCond{Tags: []string{tag}})
+					}
+				} else if filter.Tags != nil {
+					q.Nodes = append(q.Nodes, &query.TagCond{Tags: filter.Tags})
+				}
+				if filter.Project != "" {
+					q.Nodes = append(q.Nodes, &query.ProjectCond{Project: filter.Project})
+				}
+				tasks, err = a.taskStore.SearchTasks(q, today, page)
+			}
 		}
 	} else {
-		// Получаем все задачи
-		tasks, err = a.taskStore.Tasks(50)
+		// Получаем задачи начиная с сегодняшнего дня или в указанном диапазоне дат
+		filter.From, filter.To, err = listRange(values, now)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+			return
+		}
+		tasks, err = a.taskStore.Tasks(filter, page)
 	}
 
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, errResp{Error: err.Error()})
+		writeJSON(w, listErrorStatus(err), errResp{Error: err.Error()})
 		return
 	}
 
-	if tasks == nil {
-		tasks = []*db.Task{}
+	describeTasks(requestLang(r), tasks.Tasks...)
+
+	writeJSON(w, http.StatusOK, TasksResp{Tasks: tasks.Tasks, NextCursor: tasks.NextCursor, Total: tasks.Total})
+}
+
+// listErrorStatus возвращает код ответа для ошибки выборки списка задач: ошибки в поисковом
+// запросе (с указанием места в запросе), курсоре и порядке списка - ошибки клиента
+func listErrorStatus(err error) int {
+	var qerr *query.Error
+	if errors.As(err, &qerr) || errors.Is(err, db.ErrInvalidCursor) || errors.Is(err, db.ErrInvalidSort) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// parsePriorities разбирает список приоритетов через запятую и проверяет их диапазон
+func parsePriorities(s string) ([]int, error) {
+	priorities, err := query.ParsePriorities(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range priorities {
+		if p > db.MaxPriority {
+			return nil, fmt.Errorf("приоритет должен быть от 0 до %d", db.MaxPriority)
+		}
+	}
+	return priorities, nil
+}
+
+// listRange возвращает границы дат списка задач (включительно) по параметрам from и to
+// в формате 20060102; по умолчанию список начинается с сегодняшнего дня.
+// overdue=true - просроченные задачи: все задачи до вчерашнего дня включительно.
+func listRange(values url.Values, now time.Time) (from, to string, err error) {
+	if values.Get("overdue") == "true" {
+		if values.Get("from") != "" || values.Get("to") != "" {
+			return "", "", errors.New("overdue не сочетается с from и to")
+		}
+		return "", now.AddDate(0, 0, -1).Format(DateFormat), nil
 	}
 
-	writeJSON(w, http.StatusOK, TasksResp{Tasks: tasks})
+	from, to = now.Format(DateFormat), values.Get("to")
+	if s := values.Get("from"); s != "" {
+		if _, err := time.Parse(DateFormat, s); err != nil {
+			return "", "", fmt.Errorf("некорректный формат from (ожидается %s)", DateFormat)
+		}
+		from = s
+	}
+	if to != "" {
+		if _, err := time.Parse(DateFormat, to); err != nil {
+			return "", "", fmt.Errorf("некорректный формат to (ожидается %s)", DateFormat)
+		}
+		if to < from {
+			return "", "", fmt.Errorf("to (%s) раньше начала списка (%s)", to, from)
+		}
+	}
+	return from, to, nil
 }
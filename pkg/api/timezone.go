@@ -0,0 +1,25 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Заголовок, которым клиент может указать свой часовой пояс IANA (например, Europe/Moscow)
+const timezoneHeader = "X-Timezone"
+
+// now возвращает текущее время в часовом поясе запроса: из заголовка X-Timezone,
+// а если он не указан - из настроек сервера. От него считаются "сегодня" и просроченные задачи.
+func (a *API) now(r *http.Request) (time.Time, error) {
+	loc := a.location
+	if name := strings.TrimSpace(r.Header.Get(timezoneHeader)); name != "" {
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("неизвестный часовой пояс %q", name)
+		}
+	}
+	return a.clock.Now().In(loc), nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/pkg/db"
+)
+
+// Сколько операций можно отменить одним запросом
+const maxUndo = 100
+
+type undoResp struct {
+	Undone   []string `json:"undone"`             // идентификаторы восстановленных задач
+	Projects []string `json:"projects,omitempty"` // идентификаторы восстановленных проектов
+}
+
+// undoHandler отменяет n последних операций над задачами (изменение, перенос даты,
+// удаление, выполнение) и удалений проектов, сделанных не раньше, чем TODO_UNDO_WINDOW назад
+func (a *API) undoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errResp{Error: "method not allowed"})
+		return
+	}
+
+	n := 1
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		var err error
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n < 1 || n > maxUndo {
+			writeJSON(w, http.StatusBadRequest, errResp{Error: fmt.Sprintf("Параметр n должен быть от 1 до %d", maxUndo)})
+			return
+		}
+	}
+
+	undone, err := a.taskStore.Undo(n, a.clock.Now().Add(-a.config.UndoWindow))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, db.ErrProjectExists) {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, errResp{Error: err.Error()})
+		return
+	}
+	if len(undone.Tasks) == 0 && len(undone.Projects) == 0 {
+		writeJSON(w, http.StatusNotFound, errResp{Error: "нет операций для отмены"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, undoResp{Undone: undone.Tasks, Projects: undone.Projects})
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 	"todo-server/pkg/db"
 )
 
@@ -22,6 +23,7 @@ func (a *API) getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	describeTasks(requestLang(r), task)
 	writeJSON(w, http.StatusOK, task)
 }
 
@@ -51,8 +53,20 @@ func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	stored, err := a.taskStore.GetTask(task.ID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errResp{Error: err.Error()})
+		return
+	}
+	task.RepeatStart = a.keptRepeatStart(stored, &task)
+
 	// 2) проверяем и нормализуем дату (и правило повторения)
-	if err := checkDate(&task); err != nil {
+	now, err := a.now(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
+		return
+	}
+	if err := a.checkDate(&task, now); err != nil {
 		writeJSON(w, http.StatusBadRequest, errResp{Error: err.Error()})
 		return
 	}
@@ -60,10 +74,45 @@ func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	// 3) обновляем задачу в БД
 	err = a.taskStore.UpdateTask(&task)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, errResp{Error: err.Error()})
+		status := http.StatusNotFound
+		if taskProjectError(err) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, errResp{Error: err.Error()})
 		return
 	}
 
 	// Возвращаем пустой JSON объект
 	writeJSON(w, http.StatusOK, map[string]interface{}{})
 }
+
+// keptRepeatStart возвращает начало серии для изменённой задачи. Новое начало, переданное
+// клиентом, берётся как есть. Прежнее начало сохраняется, если правило не изменилось,
+// а новая дата попадает в сетку прежней серии: так правка названия не сдвигает сетку недель
+// и отсчёт рабочих дней. Иначе возвращается пустая строка - серия начнётся с даты задачи.
+func (a *API) keptRepeatStart(stored, task *db.Task) string {
+	start := strings.TrimSpace(task.RepeatStart)
+	if start != "" && start != stored.RepeatStart {
+		return start
+	}
+	if stored.RepeatStart == "" || strings.TrimSpace(task.Repeat) != strings.TrimSpace(stored.Repeat) {
+		return ""
+	}
+	if task.Date == stored.Date {
+		return stored.RepeatStart
+	}
+
+	date, err := time.Parse(DateFormat, task.Date)
+	if err != nil {
+		return ""
+	}
+	series, err := a.taskSeries(stored)
+	if err != nil {
+		return ""
+	}
+	series.Date = date
+	if !series.Origin().Equal(series.Start) {
+		return ""
+	}
+	return stored.RepeatStart
+}
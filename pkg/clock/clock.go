@@ -0,0 +1,76 @@
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Форматы, в которых можно задать момент времени для закреплённых часов
+const (
+	DateFormat     = "20060102"
+	DateTimeFormat = "20060102T150405"
+)
+
+// Clock - источник текущего времени. Всё, что зависит от "сейчас", получает время
+// через него, чтобы логику дат можно было проверить, не дожидаясь реальных дней.
+type Clock interface {
+	Now() time.Time
+}
+
+// System - системные часы
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Pinned - часы, которые можно остановить на заданном моменте (для отладки и тестов).
+// Пока момент не задан, показывают системное время.
+type Pinned struct {
+	mu sync.RWMutex
+	at time.Time
+}
+
+func (p *Pinned) Now() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.at.IsZero() {
+		return time.Now()
+	}
+	return p.at
+}
+
+// Set останавливает часы на моменте t
+func (p *Pinned) Set(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.at = t
+}
+
+// Reset возвращает часы к системному времени
+func (p *Pinned) Reset() {
+	p.Set(time.Time{})
+}
+
+// IsSet сообщает, закреплены ли часы
+func (p *Pinned) IsSet() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return !p.at.IsZero()
+}
+
+// Parse разбирает момент времени в формате 20060102, 20060102T150405 или RFC 3339;
+// дата и время без пояса считаются в поясе loc
+func Parse(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{DateFormat, DateTimeFormat} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("неверный формат времени %q (ожидается %s, %s или RFC 3339)",
+		value, DateFormat, DateTimeFormat)
+}
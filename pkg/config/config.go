@@ -9,11 +9,18 @@ import (
 )
 
 type Config struct {
-	TokenDuration time.Duration
-	DBFile        string
-	Password      string
-	JWTSecret     string
-	Port          string
+	TokenDuration    time.Duration
+	DBFile           string
+	DBDSN            string // строка подключения к PostgreSQL (если задана, DBFile не используется)
+	Password         string
+	JWTSecret        string
+	Port             string
+	HolidaysFile     string        // файл производственного календаря (.json или .ics)
+	TimeZone         string        // часовой пояс IANA для расчёта "сегодня" (пусто - местное время сервера)
+	Now              string        // закреплённое текущее время сервера для тестов (пусто - системное)
+	Debug            bool          // включает отладочные эндпоинты
+	ArchiveRetention time.Duration // сколько хранить выполненные и удалённые задачи в архиве (0 - бессрочно)
+	UndoWindow       time.Duration // в течение какого времени операцию над задачей можно отменить
 }
 
 func Load() *Config {
@@ -23,11 +30,18 @@ func Load() *Config {
 	}
 
 	cfg := &Config{
-		TokenDuration: parseDuration("TOKEN_DURATION", 8*time.Hour),
-		DBFile:        getEnv("TODO_DBFILE", "scheduler.db"),
-		Password:      getEnv("TODO_PASSWORD", ""),
-		JWTSecret:     getEnv("JWT_SECRET", ""),
-		Port:          port,
+		TokenDuration:    parseDuration("TOKEN_DURATION", 8*time.Hour),
+		DBFile:           getEnv("TODO_DBFILE", "scheduler.db"),
+		DBDSN:            getEnv("TODO_DB_DSN", ""),
+		Password:         getEnv("TODO_PASSWORD", ""),
+		JWTSecret:        getEnv("JWT_SECRET", ""),
+		Port:             port,
+		HolidaysFile:     getEnv("TODO_HOLIDAYS", ""),
+		TimeZone:         getEnv("TODO_TZ", ""),
+		Now:              getEnv("TODO_NOW", ""),
+		Debug:            parseBool("TODO_DEBUG", false),
+		ArchiveRetention: parseDuration("TODO_ARCHIVE_RETENTION", 0),
+		UndoWindow:       parseDuration("TODO_UNDO_WINDOW", time.Hour),
 	}
 
 	// Fallback для JWTSecret
@@ -38,6 +52,18 @@ func Load() *Config {
 	return cfg
 }
 
+// Location возвращает настроенный часовой пояс
+func (c *Config) Location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q: %v", c.TimeZone, err)
+	}
+	return loc, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -62,6 +88,14 @@ func parseDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+func parseBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func getPasswordHash(password string) string {
 	hash := sha256.Sum256([]byte(password))
 	return fmt.Sprintf("%x", hash)
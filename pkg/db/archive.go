@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+	"todo-server/pkg/clock"
+)
+
+// Статусы задач в архиве. У активных задач статус пустой.
+const (
+	StatusDone    = "done"
+	StatusDeleted = "deleted"
+)
+
+// Как часто проверять архив на устаревшие задачи
+const retentionInterval = time.Hour
+
+// archiveTask переносит активную задачу в архив с указанным статусом
+func (d *Database) archiveTask(id string, status string, at time.Time) error {
+	return d.journaled(id, OpDelete, func(tx *sql.Tx, before *Task) (int64, error) {
+		const query = `UPDATE scheduler SET status = ?, archived_at = ? WHERE id = ?`
+		_, err := tx.Exec(d.rebind(query), status, at.UTC().Format(time.RFC3339), before.ID)
+		return 0, err
+	})
+}
+
+// ArchivedTasks возвращает выполненные и удалённые задачи, начиная с последних
+func (d *Database) ArchivedTasks(page Page) (*TaskPage, error) {
+	return d.pageTasks(taskQuery{
+		where: []string{`status != ''`},
+		order: archiveOrder,
+	}, page)
+}
+
+// RestoreTask возвращает задачу из архива в список активных
+func (d *Database) RestoreTask(id string) error {
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return errors.New("некорректный идентификатор задачи")
+	}
+
+	const query = `UPDATE scheduler SET status = '', archived_at = '' WHERE id = ? AND status != ''`
+
+	res, err := d.db.Exec(d.rebind(query), taskID)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("задача не найдена в архиве")
+	}
+
+	return nil
+}
+
+// PurgeArchived окончательно удаляет задачи, попавшие в архив раньше before, вместе с их тегами
+func (d *Database) PurgeArchived(before time.Time) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	limit := before.UTC().Format(time.RFC3339)
+	const tags = `DELETE FROM task_tags WHERE task_id IN (SELECT id FROM scheduler WHERE status != '' AND archived_at < ?)`
+	if _, err := tx.Exec(d.rebind(tags), limit); err != nil {
+		return 0, err
+	}
+	const query = `DELETE FROM scheduler WHERE status != '' AND archived_at < ?`
+	res, err := tx.Exec(d.rebind(query), limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// RunRetention раз в час удаляет из архива задачи старше retention.
+// Блокирует выполнение, поэтому запускается в отдельной горутине.
+func (d *Database) RunRetention(retention time.Duration) {
+	runRetention(d.PurgeArchived, d.clock, retention)
+}
+
+func runRetention(purge func(before time.Time) (int64, error), clk clock.Clock, retention time.Duration) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := purge(clk.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Ошибка очистки архива: %v", err)
+		} else if n > 0 {
+			log.Printf("Из архива удалено задач: %d", n)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Completion - запись о выполнении задачи
+type Completion struct {
+	ID          string `json:"id"`
+	TaskID      string `json:"task_id"`
+	Title       string `json:"title"`        // заголовок задачи на момент выполнения
+	Date        string `json:"date"`         // дата, на которую задача была запланирована, формат 20060102
+	CompletedAt string `json:"completed_at"` // момент выполнения в UTC, RFC 3339
+}
+
+// Completions возвращает историю выполнений задачи taskID (или всех задач, если taskID пуст),
+// начиная с последних
+func (d *Database) Completions(taskID string, limit int) ([]*Completion, error) {
+	query := `SELECT id, task_id, title, date, completed_at FROM completions`
+	args := []any{}
+	if taskID != "" {
+		id, err := strconv.ParseInt(taskID, 10, 64)
+		if err != nil {
+			return nil, errors.New("некорректный идентификатор задачи")
+		}
+		query += ` WHERE task_id = ?`
+		args = append(args, id)
+	}
+	query += ` ORDER BY completed_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(d.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions := []*Completion{}
+	for rows.Next() {
+		var id, taskID int64
+		var c Completion
+		if err := rows.Scan(&id, &taskID, &c.Title, &c.Date, &c.CompletedAt); err != nil {
+			return nil, err
+		}
+		c.ID = strconv.FormatInt(id, 10)
+		c.TaskID = strconv.FormatInt(taskID, 10)
+		completions = append(completions, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return completions, nil
+}
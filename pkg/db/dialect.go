@@ -0,0 +1,55 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect - диалект SQL базы данных. Запросы пишутся с плейсхолдерами "?"
+// и приводятся к нужному виду через rebind.
+type dialect int
+
+const (
+	sqliteDialect dialect = iota
+	postgresDialect
+)
+
+// driver возвращает имя драйвера database/sql
+func (dl dialect) driver() string {
+	if dl == postgresDialect {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// rebind заменяет плейсхолдеры "?" на $1, $2, ... для PostgreSQL
+func (dl dialect) rebind(query string) string {
+	if dl != postgresDialect || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// byteOrder возвращает выражение для сортировки текстовой колонки побайтово, как в SQLite
+// и в хранилище в памяти; в PostgreSQL порядок по умолчанию зависит от локали базы
+func (dl dialect) byteOrder(column string) string {
+	if dl == postgresDialect {
+		return column + ` COLLATE "C"`
+	}
+	return column
+}
+
+func (d *Database) rebind(query string) string {
+	return d.dialect.rebind(query)
+}
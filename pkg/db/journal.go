@@ -0,0 +1,219 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Операции, которые записываются в журнал (таблица journal) вместе со снимком задачи до изменения
+const (
+	OpUpdate     = "update"
+	OpUpdateDate = "update_date"
+	OpDelete     = "delete"
+	OpDone       = "done"
+	OpMove       = "move"
+	OpSkip       = "skip"
+	// OpDeleteProject - удаление проекта: в журнал записывается снимок проекта и всех его задач
+	OpDeleteProject = "delete_project"
+)
+
+// projectSnapshot - состояние проекта и его задач до удаления
+type projectSnapshot struct {
+	Project *Project `json:"project"`
+	Tasks   []*Task  `json:"tasks"`
+}
+
+// Undone - что восстановлено отменой операций
+type Undone struct {
+	Tasks    []string // идентификаторы восстановленных задач
+	Projects []string // идентификаторы восстановленных проектов
+}
+
+// Сколько последних операций хранить в журнале
+const maxJournal = 1000
+
+// journaled выполняет операцию op над активной задачей в транзакции и записывает в журнал
+// снимок задачи до изменения. fn возвращает идентификатор записи в истории выполнений, если
+// операция её добавила, чтобы отмена могла её удалить.
+func (d *Database) journaled(id string, op string, fn func(tx *sql.Tx, before *Task) (int64, error)) error {
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return errors.New("некорректный идентификатор задачи")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + taskColumns + ` FROM scheduler WHERE id = ? AND status = ''`
+	before, err := scanTask(tx.QueryRow(d.rebind(query), taskID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("задача не найдена")
+		}
+		return err
+	}
+	if err := d.loadTags(tx, []*Task{before}); err != nil {
+		return err
+	}
+
+	completionID, err := fn(tx, before)
+	if err != nil {
+		return err
+	}
+	if err := d.addJournal(tx, taskID, op, before, completionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addJournal записывает в журнал операцию op со снимком before и удаляет записи сверх maxJournal
+func (d *Database) addJournal(tx *sql.Tx, taskID int64, op string, before any, completionID int64) error {
+	data, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	const insert = `INSERT INTO journal (task_id, operation, before, completion_id, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err = tx.Exec(d.rebind(insert), taskID, op, string(data), completionID, d.clock.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(d.rebind(`DELETE FROM journal WHERE id <= (SELECT max(id) FROM journal) - ?`), maxJournal)
+	return err
+}
+
+// Undo отменяет до n последних операций, записанных в журнал не раньше since, возвращая
+// задачам и удалённым проектам состояние до изменения
+func (d *Database) Undo(n int, since time.Time) (*Undone, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	type entry struct {
+		id           int64
+		operation    string
+		before       string
+		completionID int64
+	}
+	const query = `SELECT id, operation, before, completion_id FROM journal WHERE created_at >= ? ORDER BY id DESC LIMIT ?`
+	rows, err := tx.Query(d.rebind(query), since.UTC().Format(time.RFC3339), n)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.operation, &e.before, &e.completionID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	undone := &Undone{Tasks: []string{}, Projects: []string{}}
+	for _, e := range entries {
+		if e.operation == OpDeleteProject {
+			var snapshot projectSnapshot
+			if err := json.Unmarshal([]byte(e.before), &snapshot); err != nil {
+				return nil, err
+			}
+			if err := d.restoreProject(tx, snapshot.Project); err != nil {
+				return nil, err
+			}
+			for _, task := range snapshot.Tasks {
+				if err := d.restoreTask(tx, task); err != nil {
+					return nil, err
+				}
+				undone.Tasks = append(undone.Tasks, task.ID)
+			}
+			undone.Projects = append(undone.Projects, snapshot.Project.ID)
+		} else {
+			var task Task
+			if err := json.Unmarshal([]byte(e.before), &task); err != nil {
+				return nil, err
+			}
+			if err := d.restoreTask(tx, &task); err != nil {
+				return nil, err
+			}
+			undone.Tasks = append(undone.Tasks, task.ID)
+		}
+
+		if e.completionID > 0 {
+			if _, err := tx.Exec(d.rebind(`DELETE FROM completions WHERE id = ?`), e.completionID); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := tx.Exec(d.rebind(`DELETE FROM journal WHERE id = ?`), e.id); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(d.rebind(`DELETE FROM journal WHERE created_at < ?`), since.UTC().Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+
+	return undone, tx.Commit()
+}
+
+// restoreTask возвращает задаче состояние из журнала.
+// Задача могла быть уже окончательно удалена из архива - тогда она создаётся заново.
+func (d *Database) restoreTask(tx *sql.Tx, task *Task) error {
+	const restore = `INSERT INTO scheduler (` + taskColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET date = excluded.date, title = excluded.title,
+			comment = excluded.comment, repeat = excluded.repeat, repeat_count = excluded.repeat_count,
+			repeat_until = excluded.repeat_until, done_count = excluded.done_count,
+			exdates = excluded.exdates, repeat_start = excluded.repeat_start,
+			repeat_mode = excluded.repeat_mode, start_time = excluded.start_time,
+			duration = excluded.duration, status = excluded.status, archived_at = excluded.archived_at,
+			priority = excluded.priority, project_id = excluded.project_id`
+	project, _ := projectNum(task.ProjectID)
+	_, err := tx.Exec(d.rebind(restore), task.ID, task.Date, task.Title, task.Comment, task.Repeat,
+		task.RepeatCount, task.RepeatUntil, task.DoneCount, strings.Join(task.Exdates, ","), task.RepeatStart,
+		task.RepeatMode, task.StartTime, task.Duration, task.Status, task.ArchivedAt, task.Priority, project)
+	if err != nil {
+		return err
+	}
+	// Проект задачи мог быть удалён - тогда задача остаётся без проекта
+	const orphan = `UPDATE scheduler SET project_id = 0 WHERE id = ? AND project_id NOT IN (SELECT id FROM projects)`
+	if _, err := tx.Exec(d.rebind(orphan), task.ID); err != nil {
+		return err
+	}
+	// Теги восстанавливаются по именам: удалённые с тех пор теги создаются заново
+	return d.setTags(tx, taskNum(task), task.Tags)
+}
+
+// restoreProject создаёт удалённый проект заново с прежним идентификатором. Если его название
+// с тех пор занял другой проект, возвращает ErrProjectExists.
+func (d *Database) restoreProject(tx *sql.Tx, p *Project) error {
+	var other int64
+	err := tx.QueryRow(d.rebind(`SELECT id FROM projects WHERE name = ?`), p.Name).Scan(&other)
+	if err == nil {
+		return fmt.Errorf("проект %q не восстановлен: %w", p.Name, ErrProjectExists)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	n, err := projectNum(p.ID)
+	if err != nil {
+		return err
+	}
+	const insert = `INSERT INTO projects (id, name, color, position, archived_at) VALUES (?, ?, ?, ?, ?)`
+	_, err = tx.Exec(d.rebind(insert), n, p.Name, p.Color, p.Position, p.ArchivedAt)
+	return err
+}
@@ -0,0 +1,997 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/query"
+)
+
+// MemoryFile - значение TODO_DBFILE, при котором задачи хранятся в памяти процесса
+const MemoryFile = ":memory:"
+
+// Memory - хранилище задач в памяти процесса для тестов и демонстраций: данные теряются
+// при остановке сервера. Порядок задач, поиск, архив и журнал работают так же, как в SQLite.
+type Memory struct {
+	mu    sync.Mutex
+	clock clock.Clock
+
+	tasks       map[int64]*Task
+	tags        map[int64]string // имена тегов; у задач теги хранятся по именам в Task.Tags
+	projects    map[int64]*Project
+	completions []*memoryCompletion
+	journal     []*memoryJournalEntry
+
+	lastTaskID       int64
+	lastTagID        int64
+	lastProjectID    int64
+	lastCompletionID int64
+	lastJournalID    int64
+}
+
+type memoryCompletion struct {
+	id int64
+	Completion
+}
+
+type memoryJournalEntry struct {
+	id           int64
+	before       *Task
+	project      *projectSnapshot // снимок удалённого проекта, before в такой записи пустой
+	completionID int64
+	createdAt    string
+}
+
+// NewMemory создаёт пустое хранилище в памяти
+func NewMemory(clk clock.Clock) *Memory {
+	if clk == nil {
+		clk = clock.System{}
+	}
+	return &Memory{clock: clk, tasks: make(map[int64]*Task), tags: make(map[int64]string),
+		projects: make(map[int64]*Project)}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// copyTask возвращает копию задачи в том виде, в каком её вернула бы база
+func copyTask(task *Task) *Task {
+	res := *task
+	res.Exdates = storedExdates(task.Exdates)
+	res.Tags = nil
+	if len(task.Tags) > 0 {
+		res.Tags = slices.Clone(task.Tags)
+	}
+	res.RepeatText = ""
+	res.Snippet = ""
+	return &res
+}
+
+// storedExdates возвращает копию списка дат-исключений; в базе он хранится одной строкой
+// через запятую, поэтому пустой список читается как nil
+func storedExdates(exdates []string) []string {
+	if joined := strings.Join(exdates, ","); joined != "" {
+		return strings.Split(joined, ",")
+	}
+	return nil
+}
+
+func parseID(id string) (int64, error) {
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, errors.New("некорректный идентификатор задачи")
+	}
+	return taskID, nil
+}
+
+// activeTask возвращает задачу, если она не в архиве
+func (m *Memory) activeTask(id string) (*Task, error) {
+	taskID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	task, ok := m.tasks[taskID]
+	if !ok || task.Status != "" {
+		return nil, errors.New("задача не найдена")
+	}
+	return task, nil
+}
+
+func (m *Memory) AddTask(task *Task) (int64, error) {
+	tags, err := NormalizeTags(task.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	project, err := m.taskProject(task.ProjectID)
+	if err != nil {
+		return 0, err
+	}
+
+	m.lastTaskID++
+	stored := copyTask(task)
+	stored.ProjectID = projectID(project)
+	stored.ID = strconv.FormatInt(m.lastTaskID, 10)
+	stored.DoneCount = 0
+	stored.Status = ""
+	stored.ArchivedAt = ""
+	stored.Tags = tags
+	m.addTags(tags)
+	m.tasks[m.lastTaskID] = stored
+	return m.lastTaskID, nil
+}
+
+func (m *Memory) GetTask(id string) (*Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, err := m.activeTask(id)
+	if err != nil {
+		return nil, err
+	}
+	return copyTask(task), nil
+}
+
+// journaled выполняет операцию над активной задачей и записывает в журнал её снимок до изменения
+// (см. Database.journaled). Вызывается под блокировкой.
+func (m *Memory) journaled(id string, fn func(task *Task) int64) error {
+	task, err := m.activeTask(id)
+	if err != nil {
+		return err
+	}
+
+	before := copyTask(task)
+	completionID := fn(task)
+	m.addJournal(&memoryJournalEntry{before: before, completionID: completionID})
+	return nil
+}
+
+// addJournal записывает операцию в журнал и удаляет записи сверх maxJournal.
+// Вызывается под блокировкой.
+func (m *Memory) addJournal(e *memoryJournalEntry) {
+	m.lastJournalID++
+	e.id = m.lastJournalID
+	e.createdAt = m.clock.Now().UTC().Format(time.RFC3339)
+	m.journal = append(m.journal, e)
+	for len(m.journal) > 0 && m.journal[0].id <= m.lastJournalID-maxJournal {
+		m.journal = m.journal[1:]
+	}
+}
+
+func (m *Memory) UpdateTask(task *Task) error {
+	tags, err := NormalizeTags(task.Tags)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Задача может оставаться в проекте из архива, но перенести в него нельзя
+	project := task.ProjectID
+	if stored, err := m.activeTask(task.ID); err == nil {
+		project = stored.ProjectID
+		if task.ProjectID != stored.ProjectID {
+			n, err := m.taskProject(task.ProjectID)
+			if err != nil {
+				return err
+			}
+			project = projectID(n)
+		}
+	}
+
+	return m.journaled(task.ID, func(stored *Task) int64 {
+		updated := copyTask(task)
+		stored.Date = updated.Date
+		stored.Title = updated.Title
+		stored.Comment = updated.Comment
+		stored.Repeat = updated.Repeat
+		stored.DoneCount = doneCount(stored, updated)
+		stored.RepeatCount = updated.RepeatCount
+		stored.RepeatUntil = updated.RepeatUntil
+		stored.Exdates = updated.Exdates
+		stored.RepeatStart = updated.RepeatStart
+		stored.RepeatMode = updated.RepeatMode
+		stored.StartTime = updated.StartTime
+		stored.Duration = updated.Duration
+		stored.Priority = updated.Priority
+		stored.Tags = tags
+		stored.ProjectID = project
+		m.addTags(tags)
+		return 0
+	})
+}
+
+// DeleteTask переносит задачу в архив как удалённую
+func (m *Memory) DeleteTask(id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.journaled(id, func(task *Task) int64 {
+		task.Status = StatusDeleted
+		task.ArchivedAt = at.UTC().Format(time.RFC3339)
+		return 0
+	})
+}
+
+func (m *Memory) UpdateDate(id string, newDate string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.journaled(id, func(task *Task) int64 {
+		task.Date = newDate
+		return 0
+	})
+}
+
+// CompleteTask записывает выполнение в историю и переносит задачу на nextDate
+// или, если nextDate пуст, в архив как выполненную
+func (m *Memory) CompleteTask(id string, nextDate string, repeatStart string, completedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.journaled(id, func(task *Task) int64 {
+		m.lastCompletionID++
+		m.completions = append(m.completions, &memoryCompletion{
+			id: m.lastCompletionID,
+			Completion: Completion{
+				ID:          strconv.FormatInt(m.lastCompletionID, 10),
+				TaskID:      task.ID,
+				Title:       task.Title,
+				Date:        task.Date,
+				CompletedAt: completedAt.UTC().Format(time.RFC3339),
+			},
+		})
+
+		if nextDate == "" {
+			task.Status = StatusDone
+			task.ArchivedAt = completedAt.UTC().Format(time.RFC3339)
+		} else {
+			task.Date = nextDate
+			task.RepeatStart = repeatStart
+			task.DoneCount++
+		}
+		return m.lastCompletionID
+	})
+}
+
+// UpdateExdates заменяет список дат-исключений задачи
+func (m *Memory) UpdateExdates(id string, exdates []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, err := m.activeTask(id)
+	if err != nil {
+		return err
+	}
+	task.Exdates = storedExdates(exdates)
+	return nil
+}
+
+// SkipTask заменяет даты-исключения задачи и переносит её на nextDate или, если nextDate пуст,
+// в архив как выполненную (см. Database.SkipTask)
+func (m *Memory) SkipTask(id string, exdates []string, nextDate string, repeatStart string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.journaled(id, func(task *Task) int64 {
+		task.Exdates = storedExdates(exdates)
+		if nextDate == "" {
+			task.Status = StatusDone
+			task.ArchivedAt = at.UTC().Format(time.RFC3339)
+		} else {
+			task.Date = nextDate
+			task.RepeatStart = repeatStart
+		}
+		return 0
+	})
+}
+
+// Tasks возвращает активные задачи, подходящие под фильтр, в порядке page.Sort
+func (m *Memory) Tasks(filter TaskFilter, page Page) (*TaskPage, error) {
+	// Задачи в памяти упорядочиваются так же, как в SQLite
+	order, err := orderBy(page.Sort, sqliteDialect)
+	if err != nil {
+		return nil, err
+	}
+	project, err := projectNum(filter.Project)
+	if err != nil {
+		return nil, err
+	}
+	return m.selectTasks(page, order, func(task *Task) bool {
+		if filter.Project == "" {
+			return filter.match(task) && !m.inArchivedProject(task)
+		}
+		return filter.match(task) && task.ProjectID == projectID(project)
+	})
+}
+
+// SearchTasks ищет задачи по запросу так же, как полнотекстовый поиск FTS5 в SQLite,
+// и упорядочивает их по релевантности bm25
+func (m *Memory) SearchTasks(q *query.Query, today string, page Page) (*TaskPage, error) {
+	f, err := newSearchFilter(q, today)
+	if err != nil {
+		return nil, err
+	}
+	order, err := orderBy(page.Sort, sqliteDialect)
+	if err != nil {
+		return nil, err
+	}
+	terms := f.terms
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Как и индекс FTS5, статистика для bm25 учитывает все задачи, в том числе архивные
+	type match struct {
+		task *Task
+		freq []float64
+		size int
+	}
+	var matches []match
+	hits := make([]int, len(terms))
+	totalSize := 0
+	for _, task := range m.tasks {
+		columns := [][]token{tokenize(task.Title), tokenize(task.Comment)}
+		mt := match{task: task, freq: make([]float64, len(terms))}
+		found := f.matchFields(task) && (f.scoped() || !m.inArchivedProject(task))
+		for _, term := range f.excluded {
+			if len(term.instances(columns[0])) > 0 || len(term.instances(columns[1])) > 0 {
+				found = false
+			}
+		}
+		for i, term := range terms {
+			for _, column := range columns {
+				mt.freq[i] += float64(len(term.instances(column)))
+			}
+			if mt.freq[i] > 0 {
+				hits[i]++
+			} else {
+				found = false
+			}
+		}
+		for _, column := range columns {
+			mt.size += len(column)
+		}
+		totalSize += mt.size
+		if found {
+			matches = append(matches, mt)
+		}
+	}
+
+	// bm25 с параметрами FTS5 (k1 = 1.2, b = 0.75); меньше - релевантнее
+	const k1, b = 1.2, 0.75
+	rows := len(m.tasks)
+	avgSize := float64(totalSize) / float64(rows)
+	idf := make([]float64, len(terms))
+	for i, n := range hits {
+		idf[i] = math.Log((float64(rows-n) + 0.5) / (float64(n) + 0.5))
+		if idf[i] <= 0 {
+			idf[i] = 1e-6
+		}
+	}
+	// Без слов для поиска или с явным порядком задачи не ранжируются
+	var scores map[*Task]float64
+	if len(terms) > 0 && page.Sort == "" {
+		scores = make(map[*Task]float64, len(matches))
+	}
+	tasks := make([]*Task, 0, len(matches))
+	for _, mt := range matches {
+		score := 0.0
+		for i, freq := range mt.freq {
+			score += idf[i] * ((freq * (k1 + 1.0)) / (freq + k1*(1-b+b*float64(mt.size)/avgSize)))
+		}
+		if scores != nil {
+			scores[mt.task] = -score
+		}
+		tasks = append(tasks, mt.task)
+	}
+
+	res, err := paginate(tasks, scores, order, page)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) > 0 {
+		withSnippets(res.Tasks, terms)
+	}
+	return res, nil
+}
+
+// ArchivedTasks возвращает выполненные и удалённые задачи, начиная с последних
+func (m *Memory) ArchivedTasks(page Page) (*TaskPage, error) {
+	return m.selectTasks(page, archiveOrder, func(task *Task) bool {
+		return task.Status != ""
+	})
+}
+
+// RestoreTask возвращает задачу из архива в список активных
+func (m *Memory) RestoreTask(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	taskID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	task, ok := m.tasks[taskID]
+	if !ok || task.Status == "" {
+		return errors.New("задача не найдена в архиве")
+	}
+	task.Status = ""
+	task.ArchivedAt = ""
+	return nil
+}
+
+// PurgeArchived окончательно удаляет задачи, попавшие в архив раньше before
+func (m *Memory) PurgeArchived(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limit := before.UTC().Format(time.RFC3339)
+	var n int64
+	for id, task := range m.tasks {
+		if task.Status != "" && task.ArchivedAt < limit {
+			delete(m.tasks, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// RunRetention раз в час удаляет из архива задачи старше retention
+func (m *Memory) RunRetention(retention time.Duration) {
+	runRetention(m.PurgeArchived, m.clock, retention)
+}
+
+// Completions возвращает историю выполнений задачи taskID (или всех задач), начиная с последних
+func (m *Memory) Completions(taskID string, limit int) ([]*Completion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if taskID != "" {
+		id, err := parseID(taskID)
+		if err != nil {
+			return nil, err
+		}
+		taskID = strconv.FormatInt(id, 10)
+	}
+
+	var list []*memoryCompletion
+	for _, c := range m.completions {
+		if taskID == "" || c.TaskID == taskID {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CompletedAt != list[j].CompletedAt {
+			return list[i].CompletedAt > list[j].CompletedAt
+		}
+		return list[i].id > list[j].id
+	})
+
+	completions := []*Completion{}
+	for _, c := range list {
+		if limit >= 0 && len(completions) == limit {
+			break
+		}
+		completion := c.Completion
+		completions = append(completions, &completion)
+	}
+	return completions, nil
+}
+
+// Undo отменяет до n последних операций, записанных в журнал не раньше since
+func (m *Memory) Undo(n int, since time.Time) (*Undone, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from := since.UTC().Format(time.RFC3339)
+	var entries []*memoryJournalEntry
+	for i := len(m.journal) - 1; i >= 0 && len(entries) < n; i-- {
+		if m.journal[i].createdAt >= from {
+			entries = append(entries, m.journal[i])
+		}
+	}
+	// Проверяем заранее, что удалённые проекты можно восстановить: отмена выполняется целиком
+	for _, e := range entries {
+		if e.project == nil {
+			continue
+		}
+		if _, ok := m.projectNamed(e.project.Project.Name); ok {
+			return nil, fmt.Errorf("проект %q не восстановлен: %w", e.project.Project.Name, ErrProjectExists)
+		}
+	}
+
+	undone := &Undone{Tasks: []string{}, Projects: []string{}}
+	for _, e := range entries {
+		// Отменённая запись удаляется из журнала вместе с устаревшими
+		e.createdAt = ""
+
+		if e.project != nil {
+			p := e.project.Project
+			n, err := projectNum(p.ID)
+			if err != nil {
+				return nil, err
+			}
+			m.projects[n] = &Project{Name: p.Name, Color: p.Color, Position: p.Position, ArchivedAt: p.ArchivedAt}
+			for _, task := range e.project.Tasks {
+				if err := m.restoreTask(task); err != nil {
+					return nil, err
+				}
+				undone.Tasks = append(undone.Tasks, task.ID)
+			}
+			undone.Projects = append(undone.Projects, p.ID)
+		} else {
+			if err := m.restoreTask(e.before); err != nil {
+				return nil, err
+			}
+			undone.Tasks = append(undone.Tasks, e.before.ID)
+		}
+
+		if e.completionID > 0 {
+			for i, c := range m.completions {
+				if c.id == e.completionID {
+					m.completions = append(m.completions[:i], m.completions[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	kept := m.journal[:0]
+	for _, e := range m.journal {
+		if e.createdAt != "" && e.createdAt >= from {
+			kept = append(kept, e)
+		}
+	}
+	m.journal = kept
+
+	return undone, nil
+}
+
+// restoreTask возвращает задаче состояние из журнала. Задача могла быть уже окончательно
+// удалена из архива - тогда она создаётся заново. Вызывается под блокировкой.
+func (m *Memory) restoreTask(before *Task) error {
+	taskID, err := parseID(before.ID)
+	if err != nil {
+		return err
+	}
+	m.tasks[taskID] = copyTask(before)
+	m.addTags(before.Tags)
+	// Проект задачи мог быть удалён - тогда задача остаётся без проекта
+	if project, _ := projectNum(before.ProjectID); m.projects[project] == nil {
+		m.tasks[taskID].ProjectID = ""
+	}
+	return nil
+}
+
+// tagID возвращает идентификатор тега по имени. Вызывается под блокировкой.
+func (m *Memory) tagID(name string) (int64, bool) {
+	for id, tag := range m.tags {
+		if tag == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// addTags создаёт теги задачи, которых ещё нет. Вызывается под блокировкой.
+func (m *Memory) addTags(tags []string) {
+	for _, name := range tags {
+		if _, ok := m.tagID(name); !ok {
+			m.lastTagID++
+			m.tags[m.lastTagID] = name
+		}
+	}
+}
+
+// replaceTag заменяет у всех задач тег old на name или, если name пустое, убирает его.
+// Вызывается под блокировкой.
+func (m *Memory) replaceTag(old, name string) {
+	for _, task := range m.tasks {
+		i := slices.Index(task.Tags, old)
+		if i < 0 {
+			continue
+		}
+		tags := slices.Delete(slices.Clone(task.Tags), i, i+1)
+		if name != "" && !slices.Contains(tags, name) {
+			tags = append(tags, name)
+			sort.Strings(tags)
+		}
+		task.Tags = nil
+		if len(tags) > 0 {
+			task.Tags = tags
+		}
+	}
+}
+
+// Tags возвращает все теги по алфавиту с числом активных задач у каждого
+func (m *Memory) Tags() ([]*Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tags := make([]*Tag, 0, len(m.tags))
+	for id, name := range m.tags {
+		tag := &Tag{ID: strconv.FormatInt(id, 10), Name: name}
+		for _, task := range m.tasks {
+			if task.Status == "" && slices.Contains(task.Tags, name) {
+				tag.Tasks++
+			}
+		}
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// AddTag создаёт тег без задач
+func (m *Memory) AddTag(name string) (int64, error) {
+	name, err := NormalizeTag(name)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tagID(name); ok {
+		return 0, ErrTagExists
+	}
+	m.lastTagID++
+	m.tags[m.lastTagID] = name
+	return m.lastTagID, nil
+}
+
+// RenameTag переименовывает тег у всех задач
+func (m *Memory) RenameTag(id string, name string) error {
+	tagID, err := parseTagID(id)
+	if err != nil {
+		return err
+	}
+	name, err = NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if other, ok := m.tagID(name); ok && other != tagID {
+		return ErrTagExists
+	}
+	old, ok := m.tags[tagID]
+	if !ok {
+		return ErrTagNotFound
+	}
+	m.tags[tagID] = name
+	m.replaceTag(old, name)
+	return nil
+}
+
+// MergeTags объединяет тег id с тегом into: задачи с тегом id получают тег into, а тег id удаляется
+func (m *Memory) MergeTags(id string, into string) error {
+	fromID, err := parseTagID(id)
+	if err != nil {
+		return err
+	}
+	intoID, err := parseTagID(into)
+	if err != nil {
+		return err
+	}
+	if fromID == intoID {
+		return errors.New("тег нельзя объединить с самим собой")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, ok := m.tags[fromID]
+	name, ok2 := m.tags[intoID]
+	if !ok || !ok2 {
+		return ErrTagNotFound
+	}
+	m.replaceTag(from, name)
+	delete(m.tags, fromID)
+	return nil
+}
+
+// DeleteTag удаляет тег у всех задач
+func (m *Memory) DeleteTag(id string) error {
+	tagID, err := parseTagID(id)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name, ok := m.tags[tagID]
+	if !ok {
+		return ErrTagNotFound
+	}
+	m.replaceTag(name, "")
+	delete(m.tags, tagID)
+	return nil
+}
+
+// taskProject проверяет, что в проект можно добавить задачу, и возвращает его номер.
+// Вызывается под блокировкой.
+func (m *Memory) taskProject(id string) (int64, error) {
+	n, err := projectNum(id)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	p, ok := m.projects[n]
+	if !ok {
+		return 0, ErrProjectNotFound
+	}
+	if p.ArchivedAt != "" {
+		return 0, ErrProjectArchived
+	}
+	return n, nil
+}
+
+// inArchivedProject сообщает, что задача в проекте из архива. Вызывается под блокировкой.
+func (m *Memory) inArchivedProject(task *Task) bool {
+	project, _ := projectNum(task.ProjectID)
+	p := m.projects[project]
+	return p != nil && p.ArchivedAt != ""
+}
+
+// projectNamed возвращает номер проекта с названием name. Вызывается под блокировкой.
+func (m *Memory) projectNamed(name string) (int64, bool) {
+	for id, p := range m.projects {
+		if p.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Projects возвращает активные проекты или, если archived, проекты из архива
+// по порядку position с числом активных задач в каждом
+func (m *Memory) Projects(archived bool) ([]*Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	projects := []*Project{}
+	for id, stored := range m.projects {
+		if (stored.ArchivedAt != "") != archived {
+			continue
+		}
+		p := *stored
+		p.ID = strconv.FormatInt(id, 10)
+		for _, task := range m.tasks {
+			if task.Status == "" && task.ProjectID == p.ID {
+				p.Tasks++
+			}
+		}
+		projects = append(projects, &p)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Position != projects[j].Position {
+			return projects[i].Position < projects[j].Position
+		}
+		a, _ := projectNum(projects[i].ID)
+		b, _ := projectNum(projects[j].ID)
+		return a < b
+	})
+	return projects, nil
+}
+
+// AddProject создаёт проект. Если position не указан, проект встаёт в конец списка.
+func (m *Memory) AddProject(p *Project) (int64, error) {
+	if err := checkProject(p); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.projectNamed(p.Name); ok {
+		return 0, ErrProjectExists
+	}
+	position := p.Position
+	if position == 0 {
+		for _, other := range m.projects {
+			position = max(position, other.Position)
+		}
+		position++
+	}
+	m.lastProjectID++
+	m.projects[m.lastProjectID] = &Project{Name: p.Name, Color: p.Color, Position: position}
+	return m.lastProjectID, nil
+}
+
+// UpdateProject меняет название, цвет и, если он указан, position проекта
+func (m *Memory) UpdateProject(p *Project) error {
+	n, err := projectNum(p.ID)
+	if err != nil {
+		return err
+	}
+	if err := checkProject(p); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if other, ok := m.projectNamed(p.Name); ok && other != n {
+		return ErrProjectExists
+	}
+	stored, ok := m.projects[n]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	stored.Name = p.Name
+	stored.Color = p.Color
+	if p.Position > 0 {
+		stored.Position = p.Position
+	}
+	return nil
+}
+
+// ArchiveProject переносит проект в архив
+func (m *Memory) ArchiveProject(id string, at time.Time) error {
+	n, err := projectNum(id)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[n]
+	if !ok || p.ArchivedAt != "" {
+		return ErrProjectNotFound
+	}
+	p.ArchivedAt = at.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// UnarchiveProject возвращает проект из архива
+func (m *Memory) UnarchiveProject(id string) error {
+	n, err := projectNum(id)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.projects[n]
+	if !ok || p.ArchivedAt == "" {
+		return errors.New("проект не найден в архиве")
+	}
+	p.ArchivedAt = ""
+	return nil
+}
+
+// DeleteProject удаляет проект вместе с задачами или переносит его задачи в проект moveTo
+// (см. Database.DeleteProject)
+func (m *Memory) DeleteProject(id string, cascade bool, moveTo string, at time.Time) error {
+	n, err := projectNum(id)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target := int64(0)
+	if !cascade {
+		if target, err = m.taskProject(moveTo); err != nil {
+			return err
+		}
+		if target == n {
+			return errors.New("задачи нельзя перенести в удаляемый проект")
+		}
+	}
+	stored, ok := m.projects[n]
+	if !ok {
+		return ErrProjectNotFound
+	}
+
+	// В журнал записывается снимок проекта и всех его задач (см. Database.DeleteProject)
+	snapshot := &projectSnapshot{Project: &Project{ID: projectID(n), Name: stored.Name, Color: stored.Color,
+		Position: stored.Position, ArchivedAt: stored.ArchivedAt}, Tasks: []*Task{}}
+	for _, id := range slices.Sorted(maps.Keys(m.tasks)) {
+		if m.tasks[id].ProjectID == projectID(n) {
+			snapshot.Tasks = append(snapshot.Tasks, copyTask(m.tasks[id]))
+		}
+	}
+	m.addJournal(&memoryJournalEntry{project: snapshot})
+
+	for _, task := range m.tasks {
+		if task.ProjectID != projectID(n) {
+			continue
+		}
+		if cascade && task.Status == "" {
+			task.Status = StatusDeleted
+			task.ArchivedAt = at.UTC().Format(time.RFC3339)
+		}
+		task.ProjectID = projectID(target)
+	}
+	delete(m.projects, n)
+	return nil
+}
+
+// MoveTask переносит задачу в проект project (пустой - убирает задачу из проекта)
+func (m *Memory) MoveTask(id string, project string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.activeTask(id); err != nil {
+		return err
+	}
+	n, err := m.taskProject(project)
+	if err != nil {
+		return err
+	}
+	return m.journaled(id, func(task *Task) int64 {
+		task.ProjectID = projectID(n)
+		return 0
+	})
+}
+
+// Порядок задач в выборках
+var (
+	byDate = func(a, b *Task) bool {
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.StartTime != b.StartTime {
+			return a.StartTime < b.StartTime
+		}
+		return taskNum(a) < taskNum(b)
+	}
+	byPriority = func(a, b *Task) bool {
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return byDate(a, b)
+	}
+	byTitle = func(a, b *Task) bool {
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return byDate(a, b)
+	}
+	byArchivedAt = func(a, b *Task) bool {
+		if a.ArchivedAt != b.ArchivedAt {
+			return a.ArchivedAt > b.ArchivedAt
+		}
+		return taskNum(a) > taskNum(b)
+	}
+)
+
+func taskNum(task *Task) int64 {
+	id, _ := strconv.ParseInt(task.ID, 10, 64)
+	return id
+}
+
+// selectTasks возвращает страницу копий подходящих задач в порядке order
+func (m *Memory) selectTasks(page Page, order taskOrder, match func(task *Task) bool) (*TaskPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := []*Task{}
+	for _, task := range m.tasks {
+		if match(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	return paginate(tasks, nil, order, page)
+}
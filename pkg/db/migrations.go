@@ -0,0 +1,387 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"todo-server/pkg/clock"
+)
+
+// Migration - версия схемы базы данных
+type Migration struct {
+	Version int
+	Name    string
+	up      func(tx *sql.Tx) error
+}
+
+// sqliteMigrations - все версии схемы SQLite по порядку. Уже выпущенные миграции не меняются,
+// изменения схемы добавляются новыми миграциями в конец списка.
+//
+// Базы, созданные до появления миграций, могут уже содержать часть таблиц и колонок,
+// поэтому миграции создают только то, чего ещё нет.
+var sqliteMigrations = []Migration{
+	{1, "таблица задач scheduler", execSQL(`
+		CREATE TABLE IF NOT EXISTS scheduler (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date CHAR(8) NOT NULL,
+			title TEXT NOT NULL,
+			comment TEXT,
+			repeat TEXT
+		);
+		CREATE INDEX IF NOT EXISTS task_date ON scheduler(date);
+	`)},
+	{2, "условия окончания повторений", addColumns(
+		"repeat_count INTEGER NOT NULL DEFAULT 0",
+		"repeat_until CHAR(8) NOT NULL DEFAULT ''",
+		"done_count INTEGER NOT NULL DEFAULT 0",
+	)},
+	{3, "даты-исключения повторений", addColumns(
+		"exdates TEXT NOT NULL DEFAULT ''",
+	)},
+	{4, "начало серии повторений", addColumns(
+		"repeat_start CHAR(8) NOT NULL DEFAULT ''",
+	)},
+	{5, "режим отсчёта повторений", addColumns(
+		"repeat_mode TEXT NOT NULL DEFAULT ''",
+	)},
+	{6, "время начала и длительность", addColumns(
+		"start_time CHAR(5) NOT NULL DEFAULT ''",
+		"duration INTEGER NOT NULL DEFAULT 0",
+	)},
+	{7, "история выполнений completions", execSQL(`
+		CREATE TABLE IF NOT EXISTS completions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			date CHAR(8) NOT NULL,
+			completed_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS completions_task ON completions(task_id);
+	`)},
+	{8, "архив задач", addColumns(
+		"status TEXT NOT NULL DEFAULT ''",
+		"archived_at TEXT NOT NULL DEFAULT ''",
+	)},
+	{9, "журнал операций journal", execSQL(`
+		CREATE TABLE IF NOT EXISTS journal (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			operation TEXT NOT NULL,
+			before TEXT NOT NULL,
+			completion_id INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		);
+	`)},
+	{10, "полнотекстовый поиск scheduler_fts", execSQL(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+			title, comment,
+			content = 'scheduler', content_rowid = 'id',
+			tokenize = 'unicode61 remove_diacritics 0'
+		);
+
+		CREATE TRIGGER IF NOT EXISTS scheduler_fts_insert AFTER INSERT ON scheduler BEGIN
+			INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+		END;
+		CREATE TRIGGER IF NOT EXISTS scheduler_fts_delete AFTER DELETE ON scheduler BEGIN
+			INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment)
+			VALUES ('delete', old.id, old.title, old.comment);
+		END;
+		CREATE TRIGGER IF NOT EXISTS scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler BEGIN
+			INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment)
+			VALUES ('delete', old.id, old.title, old.comment);
+			INSERT INTO scheduler_fts (rowid, title, comment) VALUES (new.id, new.title, new.comment);
+		END;
+
+		INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild');
+	`)},
+	{11, "приоритет задач", addColumns(
+		"priority INTEGER NOT NULL DEFAULT 0",
+	)},
+	{12, "теги задач tags и task_tags", execSQL(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (task_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS task_tags_tag ON task_tags(tag_id);
+	`)},
+	{13, "проекты projects", steps(
+		execSQL(`
+			CREATE TABLE IF NOT EXISTS projects (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				color TEXT NOT NULL DEFAULT '',
+				position INTEGER NOT NULL DEFAULT 0,
+				archived_at TEXT NOT NULL DEFAULT ''
+			);
+		`),
+		addColumns("project_id INTEGER NOT NULL DEFAULT 0"),
+		execSQL(`CREATE INDEX IF NOT EXISTS task_project ON scheduler(project_id);`),
+	)},
+	// Повторяющиеся задачи из баз до версии 6 считали серию от текущей даты задачи, которая
+	// меняется при каждом выполнении; закрепляем начало серии, чтобы сетка недель не сдвигалась
+	{14, "начало серий повторений", execSQL(`
+		UPDATE scheduler SET repeat_start = date WHERE repeat != '' AND repeat_start = '';
+	`)},
+}
+
+// postgresMigrations - версии схемы PostgreSQL. Поддержка PostgreSQL появилась, когда схема
+// SQLite была уже на версии 9, поэтому первая миграция сразу создаёт её полный аналог.
+var postgresMigrations = []Migration{
+	{1, "таблицы задач, истории выполнений и журнала", execSQL(`
+		CREATE TABLE IF NOT EXISTS scheduler (
+			id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			date TEXT NOT NULL,
+			title TEXT NOT NULL,
+			comment TEXT NOT NULL DEFAULT '',
+			repeat TEXT NOT NULL DEFAULT '',
+			repeat_count INTEGER NOT NULL DEFAULT 0,
+			repeat_until TEXT NOT NULL DEFAULT '',
+			done_count INTEGER NOT NULL DEFAULT 0,
+			exdates TEXT NOT NULL DEFAULT '',
+			repeat_start TEXT NOT NULL DEFAULT '',
+			repeat_mode TEXT NOT NULL DEFAULT '',
+			start_time TEXT NOT NULL DEFAULT '',
+			duration INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT '',
+			archived_at TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS task_date ON scheduler(date);
+
+		CREATE TABLE IF NOT EXISTS completions (
+			id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			task_id BIGINT NOT NULL,
+			title TEXT NOT NULL,
+			date TEXT NOT NULL,
+			completed_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS completions_task ON completions(task_id);
+
+		CREATE TABLE IF NOT EXISTS journal (
+			id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			task_id BIGINT NOT NULL,
+			operation TEXT NOT NULL,
+			before TEXT NOT NULL,
+			completion_id BIGINT NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		);
+	`)},
+	{2, "полнотекстовый поиск", execSQL(`
+		CREATE INDEX IF NOT EXISTS scheduler_search ON scheduler
+		USING GIN (to_tsvector('simple', title || ' ' || comment));
+	`)},
+	{3, "приоритет задач", execSQL(`
+		ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+	`)},
+	{4, "теги задач tags и task_tags", execSQL(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE
+		);
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id BIGINT NOT NULL,
+			tag_id BIGINT NOT NULL,
+			PRIMARY KEY (task_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS task_tags_tag ON task_tags(tag_id);
+	`)},
+	{5, "проекты", execSQL(`
+		CREATE TABLE IF NOT EXISTS projects (
+			id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			color TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0,
+			archived_at TEXT NOT NULL DEFAULT ''
+		);
+		ALTER TABLE scheduler ADD COLUMN IF NOT EXISTS project_id BIGINT NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS task_project ON scheduler(project_id);
+	`)},
+	{6, "начало серий повторений", execSQL(`
+		UPDATE scheduler SET repeat_start = date WHERE repeat != '' AND repeat_start = '';
+	`)},
+}
+
+// Таблица применённых миграций
+const migrationsSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TEXT NOT NULL
+);
+`
+
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// steps выполняет шаги миграции по порядку
+func steps(fns ...func(tx *sql.Tx) error) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, fn := range fns {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumns добавляет в таблицу scheduler колонки (имя и определение), которых в ней ещё нет
+func addColumns(columns ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, column := range columns {
+			name := strings.Fields(column)[0]
+
+			var exists bool
+			const query = `SELECT count(*) > 0 FROM pragma_table_info('scheduler') WHERE name = ?`
+			if err := tx.QueryRow(query, name).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if _, err := tx.Exec("ALTER TABLE scheduler ADD COLUMN " + column); err != nil {
+				return fmt.Errorf("не удалось добавить колонку %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// migrations возвращает все миграции схемы для диалекта
+func (dl dialect) migrations() []Migration {
+	if dl == postgresDialect {
+		return postgresMigrations
+	}
+	return sqliteMigrations
+}
+
+// PendingMigrations возвращает миграции, ещё не применённые к базе SQLite в файле dbFile.
+// Сама база при этом не меняется.
+func PendingMigrations(dbFile string) ([]Migration, error) {
+	if _, err := os.Stat(dbFile); errors.Is(err, os.ErrNotExist) {
+		return sqliteMigrations, nil
+	}
+	return pendingIn(sqliteDialect, dbFile)
+}
+
+// PendingPostgresMigrations возвращает миграции, ещё не применённые к базе PostgreSQL
+func PendingPostgresMigrations(dsn string) ([]Migration, error) {
+	return pendingIn(postgresDialect, dsn)
+}
+
+func pendingIn(dl dialect, source string) ([]Migration, error) {
+	db, err := sql.Open(dl.driver(), source)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	applied, err := appliedVersions(db, dl)
+	if err != nil {
+		return nil, err
+	}
+	return pending(dl.migrations(), applied)
+}
+
+// migrate применяет к базе недостающие миграции, каждую в своей транзакции
+func migrate(db *sql.DB, dl dialect, clk clock.Clock) error {
+	if _, err := db.Exec(migrationsSchema); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(db, dl)
+	if err != nil {
+		return err
+	}
+	list, err := pending(dl.migrations(), applied)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if err := apply(db, dl, m, clk.Now()); err != nil {
+			return fmt.Errorf("ошибка миграции %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func apply(db *sql.DB, dl dialect, m Migration, at time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	const query = `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(dl.rebind(query), m.Version, m.Name, at.UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appliedVersions возвращает версии применённых миграций
+func appliedVersions(db *sql.DB, dl dialect) (map[int]bool, error) {
+	applied := make(map[int]bool)
+
+	check := `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	if dl == postgresDialect {
+		check = `SELECT count(*) > 0 FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`
+	}
+	var exists bool
+	if err := db.QueryRow(check).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// pending выбирает неприменённые миграции из списка. Если база уже обновлена более новой
+// версией сервера, работать с ней нельзя.
+func pending(migrations []Migration, applied map[int]bool) ([]Migration, error) {
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return nil, fmt.Errorf("версия схемы базы данных (%d) новее, чем поддерживает сервер (%d)",
+				version, latest)
+		}
+	}
+
+	var res []Migration
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,342 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPageLimit - размер страницы, если он не указан
+const DefaultPageLimit = 50
+
+// Порядок списка активных задач
+const (
+	SortDate     = "date"     // по дате и времени начала
+	SortPriority = "priority" // сначала с наивысшим приоритетом, затем по дате
+	SortTitle    = "title"    // по заголовку, затем по дате
+)
+
+var (
+	// ErrInvalidCursor - курсор страницы повреждён или не подходит к запросу
+	ErrInvalidCursor = errors.New("некорректный курсор страницы")
+	// ErrInvalidSort - неизвестный порядок списка
+	ErrInvalidSort = errors.New("sort должен быть date, priority или title")
+)
+
+// Page - запрос страницы списка задач
+type Page struct {
+	Limit  int    // сколько задач вернуть; 0 - DefaultPageLimit
+	Cursor string // NextCursor предыдущей страницы; пустой - с начала списка
+	Sort   string // SortDate, SortPriority или SortTitle; пустой - по дате, а результаты поиска - по релевантности
+}
+
+// TaskPage - страница списка задач
+type TaskPage struct {
+	Tasks      []*Task
+	NextCursor string // курсор следующей страницы; пустой, если страница последняя
+	Total      int    // сколько всего задач в списке
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	return p.Limit
+}
+
+// cursor - ключ сортировки последней задачи страницы. Следующая страница начинается с задач,
+// которые идут в списке после него, поэтому добавление и удаление задач не сдвигает страницы.
+type cursor struct {
+	Order      string   `json:"o"`           // порядок списка, к которому относится курсор
+	Score      *float64 `json:"s,omitempty"` // релевантность в результатах поиска
+	Priority   int      `json:"p,omitempty"`
+	Title      string   `json:"n,omitempty"`
+	Date       string   `json:"d,omitempty"`
+	StartTime  string   `json:"t,omitempty"`
+	ArchivedAt string   `json:"a,omitempty"`
+	ID         int64    `json:"i"`
+}
+
+// newCursor кодирует ключ сортировки задачи в порядке order в непрозрачную для клиента строку
+func newCursor(order taskOrder, task *Task, score *float64) string {
+	c := cursor{Order: order.name, Score: score, ID: taskNum(task)}
+	for _, key := range order.keys {
+		key.set(&c, task)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseCursor разбирает курсор страницы списка в порядке order; для пустой строки возвращает nil.
+// ranked - список упорядочен по релевантности, и курсор должен её содержать.
+func parseCursor(s string, order taskOrder, ranked bool) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 || c.Order != order.name || ranked != (c.Score != nil) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// task возвращает задачу с ключом сортировки курсора
+func (c *cursor) task() *Task {
+	return &Task{ID: strconv.FormatInt(c.ID, 10), Date: c.Date, StartTime: c.StartTime,
+		ArchivedAt: c.ArchivedAt, Priority: c.Priority, Title: c.Title}
+}
+
+// sortKey - колонка, по которой упорядочен список, и её значение в курсоре
+type sortKey struct {
+	column string
+	value  func(c *cursor) any
+	set    func(c *cursor, task *Task)
+}
+
+var (
+	dateKey      = sortKey{"date", func(c *cursor) any { return c.Date }, func(c *cursor, t *Task) { c.Date = t.Date }}
+	startTimeKey = sortKey{"start_time", func(c *cursor) any { return c.StartTime }, func(c *cursor, t *Task) { c.StartTime = t.StartTime }}
+	archivedKey  = sortKey{"archived_at", func(c *cursor) any { return c.ArchivedAt }, func(c *cursor, t *Task) { c.ArchivedAt = t.ArchivedAt }}
+	idKey        = sortKey{"id", func(c *cursor) any { return c.ID }, func(*cursor, *Task) {}}
+	// Больший приоритет идёт раньше: по возрастанию упорядочивается приоритет с минусом
+	priorityKey = sortKey{"-priority", func(c *cursor) any { return -c.Priority }, func(c *cursor, t *Task) { c.Priority = t.Priority }}
+)
+
+// taskOrder - порядок задач в списке: ключи сортировки по порядку (последний - id,
+// чтобы порядок был однозначным) и такое же сравнение задач для хранилища в памяти
+type taskOrder struct {
+	name string
+	keys []sortKey
+	desc bool
+	less func(a, b *Task) bool
+}
+
+// columns возвращает колонки ключей сортировки
+func (o taskOrder) columns() []string {
+	columns := make([]string, len(o.keys))
+	for i, key := range o.keys {
+		columns[i] = key.column
+	}
+	return columns
+}
+
+// values возвращает значения ключей сортировки из курсора
+func (o taskOrder) values(c *cursor) []any {
+	values := make([]any, len(o.keys))
+	for i, key := range o.keys {
+		values[i] = key.value(c)
+	}
+	return values
+}
+
+// Архив - начиная с последних
+var archiveOrder = taskOrder{
+	name: "archived",
+	keys: []sortKey{archivedKey, idKey},
+	desc: true,
+	less: byArchivedAt,
+}
+
+// orderBy возвращает порядок активных задач для Page.Sort. Колонки и значения подставляются
+// в запрос из этого списка, а не из параметров запроса клиента.
+func orderBy(sort string, dl dialect) (taskOrder, error) {
+	switch sort {
+	case "", SortDate:
+		return taskOrder{name: SortDate, keys: []sortKey{dateKey, startTimeKey, idKey}, less: byDate}, nil
+	case SortPriority:
+		return taskOrder{name: SortPriority, keys: []sortKey{priorityKey, dateKey, startTimeKey, idKey}, less: byPriority}, nil
+	case SortTitle:
+		titleKey := sortKey{dl.byteOrder("title"), func(c *cursor) any { return c.Title }, func(c *cursor, t *Task) { c.Title = t.Title }}
+		return taskOrder{name: SortTitle, keys: []sortKey{titleKey, dateKey, startTimeKey, idKey}, less: byTitle}, nil
+	}
+	return taskOrder{}, ErrInvalidSort
+}
+
+// taskQuery - выборка задач для постраничного вывода
+type taskQuery struct {
+	join      string // соединение с индексом полнотекстового поиска
+	joinArgs  []any
+	where     []string // условия отбора, объединяются через AND
+	whereArgs []any
+	score     string // выражение релевантности, меньше - релевантнее; пустое - без ранжирования
+	scoreArgs []any
+	order     taskOrder
+}
+
+// rankedRow дочитывает после колонок задачи её релевантность
+type rankedRow struct {
+	rows  *sql.Rows
+	score *float64
+}
+
+func (r rankedRow) Scan(dest ...any) error {
+	return r.rows.Scan(append(dest, r.score)...)
+}
+
+// pageTasks возвращает страницу выборки q: задачи после курсора по порядку q.order
+// (сначала по релевантности, если она есть) и общее число задач в выборке
+func (d *Database) pageTasks(q taskQuery, page Page) (*TaskPage, error) {
+	ranked := q.score != ""
+	c, err := parseCursor(page.Cursor, q.order, ranked)
+	if err != nil {
+		return nil, err
+	}
+
+	from := `FROM scheduler ` + q.join + ` WHERE ` + strings.Join(q.where, " AND ")
+	res := &TaskPage{}
+	err = d.db.QueryRow(d.rebind(`SELECT COUNT(*) `+from), concatArgs(q.joinArgs, q.whereArgs)...).Scan(&res.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, selectArgs := taskColumns, []any(nil)
+	where, whereArgs := q.where, q.whereArgs
+	var order []string
+	var orderArgs []any
+	if ranked {
+		columns += ", " + q.score
+		selectArgs = q.scoreArgs
+		order = append(order, q.score+" ASC")
+		orderArgs = q.scoreArgs
+	}
+	direction, cmp := " ASC", ">"
+	if q.order.desc {
+		direction, cmp = " DESC", "<"
+	}
+	for _, column := range q.order.columns() {
+		order = append(order, column+direction)
+	}
+
+	// Ключ сортировки задачи больше (или меньше при обратном порядке), чем у курсора
+	if c != nil {
+		keyset := `(` + strings.Join(q.order.columns(), ", ") + `) ` + cmp + ` (` + placeholders(len(q.order.keys)) + `)`
+		keysetArgs := q.order.values(c)
+		if ranked {
+			keyset = `(` + q.score + ` > ? OR (` + q.score + ` = ? AND ` + keyset + `))`
+			keysetArgs = concatArgs(q.scoreArgs, []any{*c.Score}, q.scoreArgs, []any{*c.Score}, keysetArgs)
+		}
+		where = append(append([]string{}, where...), keyset)
+		whereArgs = concatArgs(whereArgs, keysetArgs)
+	}
+
+	sqlQuery := `
+		SELECT ` + columns + `
+		FROM scheduler ` + q.join + `
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + strings.Join(order, ", ") + `
+		LIMIT ?
+	`
+	// Лишняя задача показывает, что за страницей есть ещё задачи
+	limit := page.limit()
+	args := concatArgs(selectArgs, q.joinArgs, whereArgs, orderArgs, []any{limit + 1})
+
+	rows, err := d.db.Query(d.rebind(sqlQuery), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []float64
+	res.Tasks = []*Task{}
+	for rows.Next() {
+		var row rowScanner = rows
+		var score float64
+		if ranked {
+			row = rankedRow{rows: rows, score: &score}
+		}
+		task, err := scanTask(row)
+		if err != nil {
+			return nil, err
+		}
+		res.Tasks = append(res.Tasks, task)
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(res.Tasks) > limit {
+		res.Tasks = res.Tasks[:limit]
+		var score *float64
+		if ranked {
+			score = &scores[limit-1]
+		}
+		res.NextCursor = newCursor(q.order, res.Tasks[limit-1], score)
+	}
+	if err := d.loadTags(d.db, res.Tasks); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// paginate упорядочивает задачи и возвращает страницу page так же, как pageTasks.
+// Если scores не nil, задачи сначала упорядочиваются по релевантности (меньше - релевантнее).
+func paginate(tasks []*Task, scores map[*Task]float64, order taskOrder, page Page) (*TaskPage, error) {
+	ranked := scores != nil
+	c, err := parseCursor(page.Cursor, order, ranked)
+	if err != nil {
+		return nil, err
+	}
+
+	before := func(a *Task, scoreA float64, b *Task, scoreB float64) bool {
+		if ranked && scoreA != scoreB {
+			return scoreA < scoreB
+		}
+		return order.less(a, b)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return before(tasks[i], scores[tasks[i]], tasks[j], scores[tasks[j]])
+	})
+
+	res := &TaskPage{Total: len(tasks)}
+	if c != nil {
+		key, keyScore := c.task(), 0.0
+		if ranked {
+			keyScore = *c.Score
+		}
+		start := sort.Search(len(tasks), func(i int) bool {
+			return before(key, keyScore, tasks[i], scores[tasks[i]])
+		})
+		tasks = tasks[start:]
+	}
+
+	limit := page.limit()
+	if len(tasks) > limit {
+		last := tasks[limit-1]
+		var score *float64
+		if ranked {
+			s := scores[last]
+			score = &s
+		}
+		res.NextCursor = newCursor(order, last, score)
+		tasks = tasks[:limit]
+	}
+
+	res.Tasks = make([]*Task, len(tasks))
+	for i, task := range tasks {
+		res.Tasks[i] = copyTask(task)
+	}
+	return res, nil
+}
+
+// placeholders возвращает n параметров запроса через запятую
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// concatArgs собирает аргументы запроса в новый срез
+func concatArgs(parts ...[]any) []any {
+	var args []any
+	for _, part := range parts {
+		args = append(args, part...)
+	}
+	return args
+}
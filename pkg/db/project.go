@@ -0,0 +1,354 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Project - проект (список задач) со своим цветом и местом в списке проектов
+type Project struct {
+	ID         string `json:"id,omitempty"`
+	Name       string `json:"name"`
+	Color      string `json:"color,omitempty"`       // цвет в формате #rrggbb
+	Position   int    `json:"position"`              // место в списке проектов, по возрастанию
+	ArchivedAt string `json:"archived_at,omitempty"` // момент переноса в архив в UTC, RFC 3339
+	Tasks      int    `json:"tasks"`                 // сколько активных задач в проекте
+}
+
+// NoProject - значение TaskFilter.Project для задач без проекта
+const NoProject = "none"
+
+// MaxProjectName - наибольшая длина названия проекта в символах
+const MaxProjectName = 100
+
+var (
+	// ErrProjectNotFound - проекта с таким идентификатором нет
+	ErrProjectNotFound = errors.New("проект не найден")
+	// ErrProjectExists - проект с таким названием уже есть
+	ErrProjectExists = errors.New("проект с таким названием уже существует")
+	// ErrProjectArchived - в проект из архива нельзя добавлять задачи
+	ErrProjectArchived = errors.New("проект в архиве")
+	// ErrInvalidProject - идентификатор проекта не положительное число и не NoProject
+	ErrInvalidProject = errors.New("некорректный идентификатор проекта")
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// Задачи проектов из архива не показываются в списках, если проект не указан явно
+const inActiveProject = `project_id NOT IN (SELECT id FROM projects WHERE archived_at != '')`
+
+// checkProject проверяет и нормализует название и цвет проекта
+func checkProject(p *Project) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("не указано название проекта")
+	}
+	if utf8.RuneCountInString(p.Name) > MaxProjectName {
+		return fmt.Errorf("название проекта длиннее %d символов", MaxProjectName)
+	}
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+	if p.Color != "" && !colorPattern.MatchString(p.Color) {
+		return errors.New("цвет проекта должен быть в формате #rrggbb")
+	}
+	if p.Position < 0 {
+		return errors.New("position не может быть отрицательным")
+	}
+	return nil
+}
+
+// projectNum возвращает номер проекта задачи; пустой идентификатор и NoProject - задача без проекта
+func projectNum(id string) (int64, error) {
+	if id == "" || id == NoProject {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, ErrInvalidProject
+	}
+	return n, nil
+}
+
+// projectID возвращает идентификатор проекта задачи по номеру (пустой - без проекта)
+func projectID(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// projectCond - условие отбора задач по проекту
+type projectCond struct {
+	project int64 // номер проекта; 0 - задачи без проекта
+	negated bool
+}
+
+// taskProject проверяет, что в проект можно добавить задачу, и возвращает его номер
+func (d *Database) taskProject(tx *sql.Tx, id string) (int64, error) {
+	n, err := projectNum(id)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	var archivedAt string
+	err = tx.QueryRow(d.rebind(`SELECT archived_at FROM projects WHERE id = ?`), n).Scan(&archivedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrProjectNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if archivedAt != "" {
+		return 0, ErrProjectArchived
+	}
+	return n, nil
+}
+
+// Projects возвращает активные проекты или, если archived, проекты из архива
+// по порядку position с числом активных задач в каждом
+func (d *Database) Projects(archived bool) ([]*Project, error) {
+	where := `projects.archived_at = ''`
+	if archived {
+		where = `projects.archived_at != ''`
+	}
+	query := `
+		SELECT projects.id, projects.name, projects.color, projects.position, projects.archived_at, COUNT(scheduler.id)
+		FROM projects
+		LEFT JOIN scheduler ON scheduler.project_id = projects.id AND scheduler.status = ''
+		WHERE ` + where + `
+		GROUP BY projects.id, projects.name, projects.color, projects.position, projects.archived_at
+		ORDER BY projects.position, projects.id`
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*Project{}
+	for rows.Next() {
+		var id int64
+		var p Project
+		if err := rows.Scan(&id, &p.Name, &p.Color, &p.Position, &p.ArchivedAt, &p.Tasks); err != nil {
+			return nil, err
+		}
+		p.ID = strconv.FormatInt(id, 10)
+		projects = append(projects, &p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return projects, nil
+}
+
+// AddProject создаёт проект. Если position не указан, проект встаёт в конец списка.
+func (d *Database) AddProject(p *Project) (int64, error) {
+	if err := checkProject(p); err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	position := p.Position
+	if position == 0 {
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM projects`).Scan(&position); err != nil {
+			return 0, err
+		}
+	}
+
+	const query = `INSERT INTO projects (name, color, position) VALUES (?, ?, ?) ON CONFLICT (name) DO NOTHING RETURNING id`
+	var id int64
+	err = tx.QueryRow(d.rebind(query), p.Name, p.Color, position).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrProjectExists
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// UpdateProject меняет название, цвет и, если он указан, position проекта
+func (d *Database) UpdateProject(p *Project) error {
+	n, err := projectNum(p.ID)
+	if err != nil {
+		return err
+	}
+	if err := checkProject(p); err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var other int64
+	err = tx.QueryRow(d.rebind(`SELECT id FROM projects WHERE name = ?`), p.Name).Scan(&other)
+	if err == nil && other != n {
+		return ErrProjectExists
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	query, args := `UPDATE projects SET name = ?, color = ?`, []any{p.Name, p.Color}
+	if p.Position > 0 {
+		query += `, position = ?`
+		args = append(args, p.Position)
+	}
+	res, err := tx.Exec(d.rebind(query+` WHERE id = ?`), append(args, n)...)
+	if err != nil {
+		return err
+	}
+	if err := checkFound(res, ErrProjectNotFound); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ArchiveProject переносит проект в архив: его задачи остаются в нём, но не показываются
+// в списках без явного указания проекта
+func (d *Database) ArchiveProject(id string, at time.Time) error {
+	n, err := projectNum(id)
+	if err != nil {
+		return err
+	}
+
+	const query = `UPDATE projects SET archived_at = ? WHERE id = ? AND archived_at = ''`
+	res, err := d.db.Exec(d.rebind(query), at.UTC().Format(time.RFC3339), n)
+	if err != nil {
+		return err
+	}
+	return checkFound(res, ErrProjectNotFound)
+}
+
+// UnarchiveProject возвращает проект из архива
+func (d *Database) UnarchiveProject(id string) error {
+	n, err := projectNum(id)
+	if err != nil {
+		return err
+	}
+
+	const query = `UPDATE projects SET archived_at = '' WHERE id = ? AND archived_at != ''`
+	res, err := d.db.Exec(d.rebind(query), n)
+	if err != nil {
+		return err
+	}
+	return checkFound(res, errors.New("проект не найден в архиве"))
+}
+
+// DeleteProject удаляет проект вместе с задачами (cascade) или переносит все его задачи,
+// в том числе архивные, в проект moveTo (пустой - без проекта). При каскадном удалении
+// активные задачи переносятся в архив как удалённые. Удаление записывается в журнал одной
+// операцией со снимком проекта и всех его задач: Undo возвращает и проект, и задачи в нём.
+func (d *Database) DeleteProject(id string, cascade bool, moveTo string, at time.Time) error {
+	n, err := projectNum(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	target := int64(0)
+	if !cascade {
+		if target, err = d.taskProject(tx, moveTo); err != nil {
+			return err
+		}
+		if target == n {
+			return errors.New("задачи нельзя перенести в удаляемый проект")
+		}
+	}
+
+	snapshot, err := d.projectSnapshot(tx, n)
+	if err != nil {
+		return err
+	}
+	if err := d.addJournal(tx, 0, OpDeleteProject, snapshot, 0); err != nil {
+		return err
+	}
+
+	if cascade {
+		const archive = `UPDATE scheduler SET status = ?, archived_at = ? WHERE project_id = ? AND status = ''`
+		if _, err := tx.Exec(d.rebind(archive), StatusDeleted, at.UTC().Format(time.RFC3339), n); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(d.rebind(`UPDATE scheduler SET project_id = ? WHERE project_id = ?`), target, n); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(d.rebind(`DELETE FROM projects WHERE id = ?`), n)
+	if err != nil {
+		return err
+	}
+	if err := checkFound(res, ErrProjectNotFound); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// projectSnapshot возвращает проект n со всеми его задачами, в том числе архивными
+func (d *Database) projectSnapshot(tx *sql.Tx, n int64) (*projectSnapshot, error) {
+	p := &Project{ID: projectID(n)}
+	const project = `SELECT name, color, position, archived_at FROM projects WHERE id = ?`
+	err := tx.QueryRow(d.rebind(project), n).Scan(&p.Name, &p.Color, &p.Position, &p.ArchivedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(d.rebind(`SELECT `+taskColumns+` FROM scheduler WHERE project_id = ? ORDER BY id`), n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tasks := []*Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := d.loadTags(tx, tasks); err != nil {
+		return nil, err
+	}
+	return &projectSnapshot{Project: p, Tasks: tasks}, nil
+}
+
+// MoveTask переносит задачу в проект project (пустой - убирает задачу из проекта)
+func (d *Database) MoveTask(id string, project string) error {
+	return d.journaled(id, OpMove, func(tx *sql.Tx, before *Task) (int64, error) {
+		n, err := d.taskProject(tx, project)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(d.rebind(`UPDATE scheduler SET project_id = ? WHERE id = ?`), n, before.ID)
+		return 0, err
+	})
+}
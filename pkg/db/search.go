@@ -0,0 +1,334 @@
+package db
+
+import (
+	"html"
+	"slices"
+	"sort"
+	"strings"
+	"todo-server/pkg/query"
+	"unicode"
+)
+
+// Разметка совпадений во фрагменте и длина фрагмента в словах
+const (
+	markOpen        = "<mark>"
+	markClose       = "</mark>"
+	snippetEllipsis = "…"
+	snippetTokens   = 16
+)
+
+// searchTerm - элемент поискового запроса: слово или фраза в кавычках.
+// Задача подходит под запрос, если в ней встречаются все элементы.
+type searchTerm struct {
+	words  []string // слова в нижнем регистре; несколько слов должны идти подряд
+	prefix bool     // последнее слово - префикс (молок*)
+}
+
+// token - слово текста и его положение в исходной строке
+type token struct {
+	text       string // слово в нижнем регистре
+	start, end int
+}
+
+// isTokenRune сообщает, относится ли символ к слову. Совпадает с токенизатором unicode61
+// в SQLite FTS5: буквы, цифры и символы для частного использования.
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Co, r)
+}
+
+// tokenize разбивает текст на слова и приводит их к нижнему регистру
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if isTokenRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{text: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// searchFilter - условия поискового запроса в виде, удобном для хранилища
+type searchFilter struct {
+	terms      []searchTerm          // слова и фразы, которые должны встретиться в задаче
+	excluded   []searchTerm          // слова и фразы, которых в задаче быть не должно
+	from, to   string                // границы дат включительно (to может быть пустым)
+	repeat     []bool                // отбор повторяющихся (true) или разовых (false) задач
+	priorities []*query.PriorityCond // отбор задач по приоритету
+	tags       []*query.TagCond      // отбор задач по тегам с нормализованными именами
+	projects   []projectCond         // отбор задач по проекту
+}
+
+// newSearchFilter готовит условия запроса. Если в запросе нет ограничения даты снизу,
+// ищутся задачи начиная с today.
+func newSearchFilter(q *query.Query, today string) (*searchFilter, error) {
+	f := &searchFilter{}
+	hasFrom := false
+	empty := true
+	for _, node := range q.Nodes {
+		switch n := node.(type) {
+		case *query.Text:
+			tokens := tokenize(n.Text)
+			if len(tokens) == 0 {
+				continue
+			}
+			term := searchTerm{prefix: n.Prefix}
+			for _, t := range tokens {
+				term.words = append(term.words, t.text)
+			}
+			if n.Negated {
+				f.excluded = append(f.excluded, term)
+			} else {
+				f.terms = append(f.terms, term)
+			}
+		case *query.DateCond:
+			if n.Field != query.FieldTo && n.Date > f.from {
+				f.from = n.Date
+				hasFrom = true
+			}
+			if n.Field != query.FieldFrom && (f.to == "" || n.Date < f.to) {
+				f.to = n.Date
+			}
+		case *query.RepeatCond:
+			f.repeat = append(f.repeat, n.Repeating)
+		case *query.PriorityCond:
+			for _, p := range n.Priorities {
+				if p > MaxPriority {
+					return nil, query.Errorf(n.Source(), "приоритет должен быть от 0 до %d", MaxPriority)
+				}
+			}
+			f.priorities = append(f.priorities, n)
+		case *query.TagCond:
+			tags := make([]string, len(n.Tags))
+			for i, tag := range n.Tags {
+				name, err := NormalizeTag(tag)
+				if err != nil {
+					return nil, query.Errorf(n.Source(), "%s", err)
+				}
+				tags[i] = name
+			}
+			f.tags = append(f.tags, &query.TagCond{Src: n.Src, Tags: tags, Negated: n.Negated})
+		case *query.ProjectCond:
+			project, err := projectNum(n.Project)
+			if err != nil {
+				return nil, query.Errorf(n.Source(), "%s", err)
+			}
+			f.projects = append(f.projects, projectCond{project: project, negated: n.Negated})
+		}
+		empty = false
+	}
+
+	if empty {
+		tok := query.Token{Pos: 1}
+		if len(q.Nodes) > 0 {
+			tok = q.Nodes[0].Source()
+		}
+		return nil, query.Errorf(tok, "в запросе нет слов для поиска")
+	}
+	if !hasFrom {
+		f.from = today
+	}
+	return f, nil
+}
+
+// scoped сообщает, указан ли в запросе проект: тогда ищутся и задачи проектов из архива
+func (f *searchFilter) scoped() bool {
+	for _, p := range f.projects {
+		if !p.negated {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFields проверяет задачу по всем условиям, кроме полнотекстовых
+func (f *searchFilter) matchFields(task *Task) bool {
+	if task.Status != "" || task.Date < f.from || (f.to != "" && task.Date > f.to) {
+		return false
+	}
+	for _, repeating := range f.repeat {
+		if (task.Repeat != "") != repeating {
+			return false
+		}
+	}
+	for _, p := range f.priorities {
+		if slices.Contains(p.Priorities, task.Priority) == p.Negated {
+			return false
+		}
+	}
+	for _, t := range f.tags {
+		if slices.ContainsFunc(t.Tags, func(tag string) bool { return slices.Contains(task.Tags, tag) }) == t.Negated {
+			return false
+		}
+	}
+	for _, p := range f.projects {
+		if (projectID(p.project) == task.ProjectID) == p.negated {
+			return false
+		}
+	}
+	return true
+}
+
+// ftsQuery составляет запрос FTS5: каждое слово и фраза в кавычках, поэтому спецсимволы
+// из поисковой строки не влияют на синтаксис
+func ftsQuery(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		part := `"` + strings.Join(term.words, " ") + `"`
+		if term.prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// tsQuery составляет запрос to_tsquery для PostgreSQL
+func tsQuery(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		words := make([]string, 0, len(term.words))
+		for _, w := range term.words {
+			words = append(words, "'"+w+"'")
+		}
+		if term.prefix {
+			words[len(words)-1] += ":*"
+		}
+		parts = append(parts, "("+strings.Join(words, " <-> ")+")")
+	}
+	return strings.Join(parts, " & ")
+}
+
+// instances возвращает номера слов, с которых начинаются вхождения элемента запроса
+func (term searchTerm) instances(tokens []token) []int {
+	var res []int
+	for i := 0; i+len(term.words) <= len(tokens); i++ {
+		if term.matchAt(tokens, i) {
+			res = append(res, i)
+		}
+	}
+	return res
+}
+
+func (term searchTerm) matchAt(tokens []token, i int) bool {
+	for j, w := range term.words {
+		t := tokens[i+j].text
+		if term.prefix && j == len(term.words)-1 {
+			if !strings.HasPrefix(t, w) {
+				return false
+			}
+		} else if t != w {
+			return false
+		}
+	}
+	return true
+}
+
+// span - вхождение в тексте: номера первого и последнего слова
+type span struct {
+	first, last int
+}
+
+// matchSpans возвращает вхождения всех элементов запроса по порядку; пересекающиеся
+// вхождения объединяются
+func matchSpans(terms []searchTerm, tokens []token) []span {
+	var spans []span
+	for _, term := range terms {
+		for _, i := range term.instances(tokens) {
+			spans = append(spans, span{i, i + len(term.words) - 1})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].first < spans[j].first })
+
+	var merged []span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.first <= merged[n-1].last {
+			if s.last > merged[n-1].last {
+				merged[n-1].last = s.last
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// snippet возвращает HTML-фрагмент заголовка или комментария (того, где больше совпадений)
+// длиной до snippetTokens слов, в котором совпадения выделены тегом <mark>
+func snippet(task *Task, terms []searchTerm) string {
+	text := task.Title
+	tokens := tokenize(text)
+	spans := matchSpans(terms, tokens)
+	if commentTokens := tokenize(task.Comment); len(commentTokens) > 0 {
+		if commentSpans := matchSpans(terms, commentTokens); len(commentSpans) > len(spans) {
+			text, tokens, spans = task.Comment, commentTokens, commentSpans
+		}
+	}
+	if len(tokens) == 0 {
+		return html.EscapeString(text)
+	}
+
+	// Окно начинается за пару слов до первого совпадения
+	from := 0
+	if len(spans) > 0 {
+		from = spans[0].first - 2
+	}
+	if from > len(tokens)-snippetTokens {
+		from = len(tokens) - snippetTokens
+	}
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetTokens - 1
+	if to > len(tokens)-1 {
+		to = len(tokens) - 1
+	}
+
+	var b strings.Builder
+	start := tokens[from].start
+	if from > 0 {
+		b.WriteString(snippetEllipsis)
+	} else {
+		start = 0
+	}
+	end := tokens[to].end
+	if to == len(tokens)-1 {
+		end = len(text)
+	}
+
+	pos := start
+	for _, s := range spans {
+		if s.last < from || s.first > to {
+			continue
+		}
+		first, last := max(s.first, from), min(s.last, to)
+		b.WriteString(html.EscapeString(text[pos:tokens[first].start]))
+		b.WriteString(markOpen)
+		b.WriteString(html.EscapeString(text[tokens[first].start:tokens[last].end]))
+		b.WriteString(markClose)
+		pos = tokens[last].end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if to < len(tokens)-1 {
+		b.WriteString(snippetEllipsis)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// withSnippets заполняет фрагменты с подсветкой у найденных задач
+func withSnippets(tasks []*Task, terms []searchTerm) []*Task {
+	for _, task := range tasks {
+		task.Snippet = snippet(task, terms)
+	}
+	return tasks
+}
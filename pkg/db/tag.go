@@ -0,0 +1,313 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tag - тег задач. Задача может иметь несколько тегов, тег - относиться к нескольким задачам
+// (таблица связей task_tags).
+type Tag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Tasks int    `json:"tasks"` // сколько активных задач с этим тегом
+}
+
+// MaxTagLength - наибольшая длина имени тега в символах
+const MaxTagLength = 50
+
+var (
+	// ErrTagNotFound - тега с таким идентификатором нет
+	ErrTagNotFound = errors.New("тег не найден")
+	// ErrTagExists - тег с таким именем уже есть; теги с задачами объединяются через MergeTags
+	ErrTagExists = errors.New("тег с таким именем уже существует")
+)
+
+// querier - соединение с базой или транзакция
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// NormalizeTag проверяет имя тега и приводит его к виду, в котором оно хранится:
+// без пробелов по краям и в нижнем регистре. Запятая разделяет теги в запросах, поэтому
+// в имени её быть не может.
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "":
+		return "", errors.New("не указано имя тега")
+	case strings.Contains(name, ","):
+		return "", fmt.Errorf("имя тега %q не должно содержать запятую", name)
+	case utf8.RuneCountInString(name) > MaxTagLength:
+		return "", fmt.Errorf("имя тега длиннее %d символов", MaxTagLength)
+	}
+	return name, nil
+}
+
+// NormalizeTags нормализует имена тегов задачи, убирает повторы и упорядочивает их
+// так же, как они читаются из базы. Для пустого списка возвращает nil.
+func NormalizeTags(tags []string) ([]string, error) {
+	var res []string
+	for _, tag := range tags {
+		name, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(res, name) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func parseTagID(id string) (int64, error) {
+	tagID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, errors.New("некорректный идентификатор тега")
+	}
+	return tagID, nil
+}
+
+// withTags возвращает условие отбора задач с одним из тегов (или ни с одним из них)
+func withTags(tags []string, negated bool) (string, []any) {
+	args := make([]any, len(tags))
+	for i, tag := range tags {
+		args[i] = tag
+	}
+	op := `IN`
+	if negated {
+		op = `NOT IN`
+	}
+	return `id ` + op + ` (SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id
+		WHERE tags.name IN (` + placeholders(len(tags)) + `))`, args
+}
+
+// loadTags дочитывает теги задач одним запросом
+func (d *Database) loadTags(q querier, tasks []*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Task, len(tasks))
+	args := make([]any, len(tasks))
+	for i, task := range tasks {
+		byID[taskNum(task)] = task
+		args[i] = taskNum(task)
+	}
+
+	query := `SELECT task_tags.task_id, tags.name FROM task_tags JOIN tags ON tags.id = task_tags.tag_id
+		WHERE task_tags.task_id IN (` + placeholders(len(tasks)) + `)
+		ORDER BY ` + d.dialect.byteOrder("tags.name")
+	rows, err := q.Query(d.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var taskID int64
+		var name string
+		if err := rows.Scan(&taskID, &name); err != nil {
+			return err
+		}
+		if task := byID[taskID]; task != nil {
+			task.Tags = append(task.Tags, name)
+		}
+	}
+	return rows.Err()
+}
+
+// setTags заменяет теги задачи; теги, которых ещё нет, создаются
+func (d *Database) setTags(tx *sql.Tx, taskID int64, tags []string) error {
+	if _, err := tx.Exec(d.rebind(`DELETE FROM task_tags WHERE task_id = ?`), taskID); err != nil {
+		return err
+	}
+	for _, name := range tags {
+		if _, err := tx.Exec(d.rebind(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`), name); err != nil {
+			return err
+		}
+		const link = `INSERT INTO task_tags (task_id, tag_id) VALUES (?, (SELECT id FROM tags WHERE name = ?))`
+		if _, err := tx.Exec(d.rebind(link), taskID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tags возвращает все теги по алфавиту с числом активных задач у каждого
+func (d *Database) Tags() ([]*Tag, error) {
+	query := `
+		SELECT tags.id, tags.name, COUNT(scheduler.id)
+		FROM tags
+		LEFT JOIN task_tags ON task_tags.tag_id = tags.id
+		LEFT JOIN scheduler ON scheduler.id = task_tags.task_id AND scheduler.status = ''
+		GROUP BY tags.id, tags.name
+		ORDER BY ` + d.dialect.byteOrder("tags.name")
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var id int64
+		var tag Tag
+		if err := rows.Scan(&id, &tag.Name, &tag.Tasks); err != nil {
+			return nil, err
+		}
+		tag.ID = strconv.FormatInt(id, 10)
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// AddTag создаёт тег без задач
+func (d *Database) AddTag(name string) (int64, error) {
+	name, err := NormalizeTag(name)
+	if err != nil {
+		return 0, err
+	}
+
+	const query = `INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING RETURNING id`
+	var id int64
+	err = d.db.QueryRow(d.rebind(query), name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrTagExists
+	}
+	return id, err
+}
+
+// RenameTag переименовывает тег. Задачи ссылаются на тег по идентификатору, поэтому новое
+// имя сразу видно у всех задач. Если тег с таким именем уже есть, теги нужно объединить.
+func (d *Database) RenameTag(id string, name string) error {
+	tagID, err := parseTagID(id)
+	if err != nil {
+		return err
+	}
+	name, err = NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var other int64
+	err = tx.QueryRow(d.rebind(`SELECT id FROM tags WHERE name = ?`), name).Scan(&other)
+	if err == nil && other != tagID {
+		return ErrTagExists
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	res, err := tx.Exec(d.rebind(`UPDATE tags SET name = ? WHERE id = ?`), name, tagID)
+	if err != nil {
+		return err
+	}
+	if err := checkFound(res, ErrTagNotFound); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MergeTags объединяет тег id с тегом into в одной транзакции: задачи с тегом id получают
+// тег into (если его у них ещё нет), а тег id удаляется
+func (d *Database) MergeTags(id string, into string) error {
+	fromID, err := parseTagID(id)
+	if err != nil {
+		return err
+	}
+	intoID, err := parseTagID(into)
+	if err != nil {
+		return err
+	}
+	if fromID == intoID {
+		return errors.New("тег нельзя объединить с самим собой")
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow(d.rebind(`SELECT count(*) FROM tags WHERE id IN (?, ?)`), fromID, intoID).Scan(&n); err != nil {
+		return err
+	}
+	if n != 2 {
+		return ErrTagNotFound
+	}
+
+	// Связи с задачами, у которых уже есть тег into, просто удаляются вместе с тегом
+	const move = `UPDATE task_tags SET tag_id = ?
+		WHERE tag_id = ? AND task_id NOT IN (SELECT task_id FROM task_tags WHERE tag_id = ?)`
+	if _, err := tx.Exec(d.rebind(move), intoID, fromID, intoID); err != nil {
+		return err
+	}
+	if err := d.deleteTag(tx, fromID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTag удаляет тег у всех задач
+func (d *Database) DeleteTag(id string) error {
+	tagID, err := parseTagID(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := d.deleteTag(tx, tagID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteTag удаляет тег и его связи с задачами
+func (d *Database) deleteTag(tx *sql.Tx, tagID int64) error {
+	if _, err := tx.Exec(d.rebind(`DELETE FROM task_tags WHERE tag_id = ?`), tagID); err != nil {
+		return err
+	}
+	res, err := tx.Exec(d.rebind(`DELETE FROM tags WHERE id = ?`), tagID)
+	if err != nil {
+		return err
+	}
+	return checkFound(res, ErrTagNotFound)
+}
+
+// checkFound возвращает notFound, если запрос не затронул ни одной строки
+func checkFound(res sql.Result, notFound error) error {
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
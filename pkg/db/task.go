@@ -3,68 +3,193 @@ package db
 import (
 	"database/sql"
 	"errors"
-	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	"todo-server/pkg/query"
 )
 
 type Task struct {
-	ID      string `json:"id,omitempty"`
-	Date    string `json:"date"`  // формат 20060102 (обязательное поле)
-	Title   string `json:"title"` // обязательное поле
-	Comment string `json:"comment,omitempty"`
-	Repeat  string `json:"repeat,omitempty"` // правило повторения (может быть пустым)
+	ID          string   `json:"id,omitempty"`
+	Date        string   `json:"date"`  // формат 20060102 (обязательное поле)
+	Title       string   `json:"title"` // обязательное поле
+	Comment     string   `json:"comment,omitempty"`
+	Repeat      string   `json:"repeat,omitempty"`       // правило повторения (может быть пустым)
+	RepeatCount int      `json:"repeat_count,omitempty"` // сколько раз всего выполнить задачу (0 - без ограничения)
+	RepeatUntil string   `json:"repeat_until,omitempty"` // последняя дата повторения, формат 20060102
+	DoneCount   int      `json:"done_count,omitempty"`   // сколько раз задача уже выполнена
+	Exdates     []string `json:"exdates,omitempty"`      // даты-исключения повторений, формат 20060102
+	RepeatStart string   `json:"repeat_start,omitempty"` // дата начала серии повторений, формат 20060102
+	RepeatMode  string   `json:"repeat_mode,omitempty"`  // отсчёт повторений: schedule - по расписанию, completion - от выполнения
+	StartTime   string   `json:"start_time,omitempty"`   // время начала, формат 15:04 (пусто - на весь день)
+	Duration    int      `json:"duration,omitempty"`     // длительность в минутах
+	Status      string   `json:"status,omitempty"`       // статус в архиве: done - выполнена, deleted - удалена
+	ArchivedAt  string   `json:"archived_at,omitempty"`  // момент переноса в архив в UTC, RFC 3339
+	Priority    int      `json:"priority,omitempty"`     // приоритет от 0 (обычный) до MaxPriority (наивысший)
+	Tags        []string `json:"tags,omitempty"`         // имена тегов по алфавиту (хранятся в tags и task_tags)
+	ProjectID   string   `json:"project_id,omitempty"`   // проект задачи (пусто - без проекта)
+	RepeatText  string   `json:"repeat_text,omitempty"`  // описание правила повторения (только в ответах API, не хранится)
+	Snippet     string   `json:"snippet,omitempty"`      // HTML-фрагмент с подсвеченными совпадениями (только в результатах поиска)
+}
+
+// MaxPriority - наивысший приоритет задачи
+const MaxPriority = 3
+
+// Колонки задачи в порядке, который ожидает scanTask
+const taskColumns = `id, date, title, comment, repeat, repeat_count, repeat_until, done_count, exdates, repeat_start, repeat_mode,
+	start_time, duration, status, archived_at, priority, project_id`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask читает задачу из строки результата запроса с колонками taskColumns
+func scanTask(row rowScanner) (*Task, error) {
+	var id, project int64
+	var exdates string
+	var task Task
+	err := row.Scan(&id, &task.Date, &task.Title, &task.Comment, &task.Repeat,
+		&task.RepeatCount, &task.RepeatUntil, &task.DoneCount, &exdates, &task.RepeatStart, &task.RepeatMode,
+		&task.StartTime, &task.Duration, &task.Status, &task.ArchivedAt, &task.Priority, &project)
+	if err != nil {
+		return nil, err
+	}
+	task.ProjectID = projectID(project)
+	if exdates != "" {
+		task.Exdates = strings.Split(exdates, ",")
+	}
+	// Конвертируем int64 в string для JSON
+	task.ID = strconv.FormatInt(id, 10)
+	return &task, nil
 }
 
 func (d *Database) AddTask(task *Task) (int64, error) {
+	tags, err := NormalizeTags(task.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	project, err := d.taskProject(tx, task.ProjectID)
+	if err != nil {
+		return 0, err
+	}
+
 	const query = `
-        INSERT INTO scheduler (date, title, comment, repeat)
-        VALUES (?, ?, ?, ?)
+        INSERT INTO scheduler (date, title, comment, repeat, repeat_count, repeat_until, exdates,
+            repeat_start, repeat_mode, start_time, duration, priority, project_id)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        RETURNING id
     `
-	res, err := d.db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat)
+	var id int64
+	err = tx.QueryRow(d.rebind(query), task.Date, task.Title, task.Comment, task.Repeat,
+		task.RepeatCount, task.RepeatUntil, strings.Join(task.Exdates, ","), task.RepeatStart, task.RepeatMode,
+		task.StartTime, task.Duration, task.Priority, project).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	if err := d.setTags(tx, id, tags); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (d *Database) Tasks(limit int) ([]*Task, error) {
-	const query = `
-		SELECT id, date, title, comment, repeat 
-		FROM scheduler 
-		WHERE date >= strftime('%Y%m%d', 'now')
-		ORDER BY date ASC, id ASC 
-		LIMIT ?
-	`
+// TaskFilter - условия отбора активных задач. Границы дат передаются снаружи, чтобы
+// "сегодня" считалось в часовом поясе пользователя.
+type TaskFilter struct {
+	From, To   string   // границы дат включительно, формат 20060102; пустая граница не ограничивает список
+	Priorities []int    // допустимые приоритеты; пустой - любые
+	Tags       []string // нормализованные имена тегов (см. NormalizeTag); пустой - задачи с любыми тегами
+	AllTags    bool     // задача должна иметь все теги Tags, а не хотя бы один из них
+	Project    string   // идентификатор проекта или NoProject; пустой - задачи всех проектов, кроме архивных
+}
+
+// match проверяет задачу по условиям фильтра
+func (f TaskFilter) match(task *Task) bool {
+	return task.Status == "" && task.Date >= f.From && (f.To == "" || task.Date <= f.To) &&
+		(len(f.Priorities) == 0 || slices.Contains(f.Priorities, task.Priority)) && f.matchTags(task)
+}
 
-	rows, err := d.db.Query(query, limit)
+// matchTags проверяет теги задачи по условиям фильтра
+func (f TaskFilter) matchTags(task *Task) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, tag := range f.Tags {
+		if slices.Contains(task.Tags, tag) != f.AllTags {
+			return !f.AllTags
+		}
+	}
+	return f.AllTags
+}
+
+// inPriorities возвращает условие отбора задач с одним из приоритетов (или ни с одним из них)
+func inPriorities(priorities []int, negated bool) (string, []any) {
+	args := make([]any, len(priorities))
+	for i, p := range priorities {
+		args[i] = p
+	}
+	op := `IN`
+	if negated {
+		op = `NOT IN`
+	}
+	return `priority ` + op + ` (` + placeholders(len(priorities)) + `)`, args
+}
+
+// Tasks возвращает активные задачи, подходящие под фильтр, в порядке page.Sort
+func (d *Database) Tasks(filter TaskFilter, page Page) (*TaskPage, error) {
+	order, err := orderBy(page.Sort, d.dialect)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var tasks []*Task
-	for rows.Next() {
-		var id int64
-		var task Task
-		err := rows.Scan(&id, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	q := taskQuery{
+		where:     []string{`date >= ?`, `status = ''`},
+		whereArgs: []any{filter.From},
+		order:     order,
+	}
+	if filter.To != "" {
+		q.where = append(q.where, `date <= ?`)
+		q.whereArgs = append(q.whereArgs, filter.To)
+	}
+	if len(filter.Priorities) > 0 {
+		cond, args := inPriorities(filter.Priorities, false)
+		q.where = append(q.where, cond)
+		q.whereArgs = append(q.whereArgs, args...)
+	}
+	if filter.Project == "" {
+		q.where = append(q.where, inActiveProject)
+	} else {
+		project, err := projectNum(filter.Project)
 		if err != nil {
 			return nil, err
 		}
-		// Конвертируем int64 в string
-		task.ID = strconv.FormatInt(id, 10)
-		tasks = append(tasks, &task)
+		q.where = append(q.where, `project_id = ?`)
+		q.whereArgs = append(q.whereArgs, project)
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	// Возвращаем пустой слайс вместо nil
-	if tasks == nil {
-		tasks = []*Task{}
+	if len(filter.Tags) > 0 {
+		// Все теги - по условию на каждый тег, хотя бы один - одно условие на все
+		groups := [][]string{filter.Tags}
+		if filter.AllTags {
+			groups = groups[:0]
+			for _, tag := range filter.Tags {
+				groups = append(groups, []string{tag})
+			}
+		}
+		for _, tags := range groups {
+			cond, args := withTags(tags, false)
+			q.where = append(q.where, cond)
+			q.whereArgs = append(q.whereArgs, args...)
+		}
 	}
-
-	return tasks, nil
+	return d.pageTasks(q, page)
 }
 
 func (d *Database) GetTask(id string) (*Task, error) {
@@ -75,89 +200,115 @@ func (d *Database) GetTask(id string) (*Task, error) {
 	}
 
 	const query = `
-		SELECT id, date, title, comment, repeat 
+		SELECT ` + taskColumns + ` 
 		FROM scheduler 
-		WHERE id = ?
+		WHERE id = ? AND status = ''
 	`
 
-	var task Task
-	var dbID int64
-	err = d.db.QueryRow(query, taskID).Scan(&dbID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	task, err := scanTask(d.db.QueryRow(d.rebind(query), taskID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("задача не найдена")
 		}
 		return nil, err
 	}
+	if err := d.loadTags(d.db, []*Task{task}); err != nil {
+		return nil, err
+	}
 
-	// Конвертируем обратно в string для JSON
-	task.ID = strconv.FormatInt(dbID, 10)
-	return &task, nil
+	return task, nil
 }
 
-func (d *Database) UpdateTask(task *Task) error {
-	// Конвертируем string ID в int64 для базы данных
-	taskID, err := strconv.ParseInt(task.ID, 10, 64)
-	if err != nil {
-		return errors.New("некорректный идентификатор задачи")
+// doneCount возвращает счётчик выполнений задачи после изменения: выполнения по прежнему
+// правилу или с прежними условиями окончания не засчитываются новой серии
+func doneCount(before, after *Task) int {
+	if before.Repeat != after.Repeat || before.RepeatCount != after.RepeatCount ||
+		before.RepeatUntil != after.RepeatUntil {
+		return 0
 	}
+	return before.DoneCount
+}
 
-	const query = `
-		UPDATE scheduler 
-		SET date = ?, title = ?, comment = ?, repeat = ?
-		WHERE id = ?
-	`
-
-	res, err := d.db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, taskID)
+func (d *Database) UpdateTask(task *Task) error {
+	tags, err := NormalizeTags(task.Tags)
 	if err != nil {
 		return err
 	}
 
-	// Проверяем, что запись была обновлена
-	count, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if count == 0 {
-		return fmt.Errorf("задача не найдена")
-	}
+	return d.journaled(task.ID, OpUpdate, func(tx *sql.Tx, before *Task) (int64, error) {
+		// Задача может оставаться в проекте из архива, но перенести в него нельзя
+		project, err := projectNum(before.ProjectID)
+		if task.ProjectID != before.ProjectID {
+			project, err = d.taskProject(tx, task.ProjectID)
+		}
+		if err != nil {
+			return 0, err
+		}
 
-	return nil
+		const query = `
+			UPDATE scheduler 
+			SET date = ?, title = ?, comment = ?, repeat = ?, repeat_count = ?, repeat_until = ?,
+				done_count = ?, exdates = ?, repeat_start = ?, repeat_mode = ?, start_time = ?, duration = ?,
+				priority = ?, project_id = ?
+			WHERE id = ?
+		`
+
+		_, err = tx.Exec(d.rebind(query), task.Date, task.Title, task.Comment, task.Repeat,
+			task.RepeatCount, task.RepeatUntil, doneCount(before, task), strings.Join(task.Exdates, ","),
+			task.RepeatStart, task.RepeatMode, task.StartTime, task.Duration, task.Priority, project, before.ID)
+		if err != nil {
+			return 0, err
+		}
+		return 0, d.setTags(tx, taskNum(before), tags)
+	})
 }
 
-func (d *Database) DeleteTask(id string) error {
-	taskID, err := strconv.ParseInt(id, 10, 64)
-	if err != nil {
-		return errors.New("некорректный идентификатор задачи")
-	}
-
-	const query = `DELETE FROM scheduler WHERE id = ?`
+// DeleteTask переносит задачу в архив как удалённую; окончательно её удаляет очистка архива
+func (d *Database) DeleteTask(id string, at time.Time) error {
+	return d.archiveTask(id, StatusDeleted, at)
+}
 
-	res, err := d.db.Exec(query, taskID)
-	if err != nil {
-		return err
-	}
+func (d *Database) UpdateDate(id string, newDate string) error {
+	return d.journaled(id, OpUpdateDate, func(tx *sql.Tx, before *Task) (int64, error) {
+		_, err := tx.Exec(d.rebind(`UPDATE scheduler SET date = ? WHERE id = ?`), newDate, before.ID)
+		return 0, err
+	})
+}
 
-	count, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if count == 0 {
-		return errors.New("задача не найдена")
-	}
+// CompleteTask отмечает задачу выполненной: записывает выполнение в историю и в той же
+// транзакции переносит повторяющуюся задачу на следующую дату (обновляя начало серии
+// и счётчик выполнений) или, если nextDate пуст, переносит задачу в архив как выполненную
+func (d *Database) CompleteTask(id string, nextDate string, repeatStart string, completedAt time.Time) error {
+	return d.journaled(id, OpDone, func(tx *sql.Tx, before *Task) (int64, error) {
+		const insert = `INSERT INTO completions (task_id, title, date, completed_at) VALUES (?, ?, ?, ?) RETURNING id`
+		var completionID int64
+		err := tx.QueryRow(d.rebind(insert), before.ID, before.Title, before.Date,
+			completedAt.UTC().Format(time.RFC3339)).Scan(&completionID)
+		if err != nil {
+			return 0, err
+		}
 
-	return nil
+		if nextDate == "" {
+			const query = `UPDATE scheduler SET status = ?, archived_at = ? WHERE id = ?`
+			_, err = tx.Exec(d.rebind(query), StatusDone, completedAt.UTC().Format(time.RFC3339), before.ID)
+		} else {
+			const query = `UPDATE scheduler SET date = ?, repeat_start = ?, done_count = done_count + 1 WHERE id = ?`
+			_, err = tx.Exec(d.rebind(query), nextDate, repeatStart, before.ID)
+		}
+		return completionID, err
+	})
 }
 
-func (d *Database) UpdateDate(id string, newDate string) error {
+// UpdateExdates заменяет список дат-исключений задачи
+func (d *Database) UpdateExdates(id string, exdates []string) error {
 	taskID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		return errors.New("некорректный идентификатор задачи")
 	}
 
-	const query = `UPDATE scheduler SET date = ? WHERE id = ?`
+	const query = `UPDATE scheduler SET exdates = ? WHERE id = ? AND status = ''`
 
-	res, err := d.db.Exec(query, newDate, taskID)
+	res, err := d.db.Exec(d.rebind(query), strings.Join(exdates, ","), taskID)
 	if err != nil {
 		return err
 	}
@@ -173,67 +324,111 @@ func (d *Database) UpdateDate(id string, newDate string) error {
 	return nil
 }
 
-func (d *Database) SearchTasksByText(search string, limit int) ([]*Task, error) {
-	searchPattern := "%" + search + "%"
-	query := `
-		SELECT id, date, title, comment, repeat 
-		FROM scheduler 
-		WHERE (title LIKE ? OR comment LIKE ?)
-		AND date >= strftime('%Y%m%d', 'now')
-		ORDER BY date ASC, id ASC 
-		LIMIT ?
-	`
+// SkipTask пропускает текущее повторение задачи, не записывая выполнение: заменяет даты-исключения
+// на exdates и в той же транзакции переносит задачу на nextDate (обновляя начало серии) или, если
+// nextDate пуст и повторений больше нет, переносит задачу в архив как выполненную
+func (d *Database) SkipTask(id string, exdates []string, nextDate string, repeatStart string, at time.Time) error {
+	return d.journaled(id, OpSkip, func(tx *sql.Tx, before *Task) (int64, error) {
+		var err error
+		if nextDate == "" {
+			const query = `UPDATE scheduler SET exdates = ?, status = ?, archived_at = ? WHERE id = ?`
+			_, err = tx.Exec(d.rebind(query), strings.Join(exdates, ","), StatusDone, at.UTC().Format(time.RFC3339), before.ID)
+		} else {
+			const query = `UPDATE scheduler SET exdates = ?, date = ?, repeat_start = ? WHERE id = ?`
+			_, err = tx.Exec(d.rebind(query), strings.Join(exdates, ","), nextDate, repeatStart, before.ID)
+		}
+		return 0, err
+	})
+}
 
-	rows, err := d.db.Query(query, searchPattern, searchPattern, limit)
+// SearchTasks ищет активные задачи по разобранному запросу (см. query.Parse). Условия запроса
+// превращаются в параметризованный SQL. Если в запросе есть слова для полнотекстового поиска
+// и порядок page.Sort не указан, задачи упорядочены по релевантности, затем по дате.
+func (d *Database) SearchTasks(q *query.Query, today string, page Page) (*TaskPage, error) {
+	f, err := newSearchFilter(q, today)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	return scanTasksFromRows(rows)
-}
-
-// SearchTasksByDate ищет задачи по конкретной дате
-func (d *Database) SearchTasksByDate(date string, limit int) ([]*Task, error) {
-	query := `
-		SELECT id, date, title, comment, repeat 
-		FROM scheduler 
-		WHERE date = ?
-		ORDER BY date ASC, id ASC 
-		LIMIT ?
-	`
-
-	rows, err := d.db.Query(query, date, limit)
+	order, err := orderBy(page.Sort, d.dialect)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	return scanTasksFromRows(rows)
-}
 
-// scanTasksFromRows - вспомогательная функция для сканирования задач из rows
-func scanTasksFromRows(rows *sql.Rows) ([]*Task, error) {
-	var tasks []*Task
+	const document = `to_tsvector('simple', title || ' ' || comment)`
+	tq := taskQuery{
+		where:     []string{`status = ''`, `date >= ?`},
+		whereArgs: []any{f.from},
+		order:     order,
+	}
+	if f.to != "" {
+		tq.where = append(tq.where, `date <= ?`)
+		tq.whereArgs = append(tq.whereArgs, f.to)
+	}
+	for _, repeating := range f.repeat {
+		if repeating {
+			tq.where = append(tq.where, `repeat != ''`)
+		} else {
+			tq.where = append(tq.where, `repeat = ''`)
+		}
+	}
+	for _, p := range f.priorities {
+		cond, args := inPriorities(p.Priorities, p.Negated)
+		tq.where = append(tq.where, cond)
+		tq.whereArgs = append(tq.whereArgs, args...)
+	}
+	for _, t := range f.tags {
+		cond, args := withTags(t.Tags, t.Negated)
+		tq.where = append(tq.where, cond)
+		tq.whereArgs = append(tq.whereArgs, args...)
+	}
+	for _, p := range f.projects {
+		if p.negated {
+			tq.where = append(tq.where, `project_id != ?`)
+		} else {
+			tq.where = append(tq.where, `project_id = ?`)
+		}
+		tq.whereArgs = append(tq.whereArgs, p.project)
+	}
+	if !f.scoped() {
+		tq.where = append(tq.where, inActiveProject)
+	}
 
-	for rows.Next() {
-		var id int64
-		var task Task
-		err := rows.Scan(&id, &task.Date, &task.Title, &task.Comment, &task.Repeat)
-		if err != nil {
-			return nil, err
+	ranked := page.Sort == ""
+	if len(f.terms) > 0 {
+		if d.dialect == postgresDialect {
+			tq.where = append(tq.where, document+` @@ to_tsquery('simple', ?)`)
+			tq.whereArgs = append(tq.whereArgs, tsQuery(f.terms))
+			if ranked {
+				tq.score = `-ts_rank(` + document + `, to_tsquery('simple', ?))`
+				tq.scoreArgs = []any{tsQuery(f.terms)}
+			}
+		} else {
+			tq.join = `JOIN (
+				SELECT rowid AS task_id, bm25(scheduler_fts) AS score
+				FROM scheduler_fts WHERE scheduler_fts MATCH ?
+			) AS fts ON fts.task_id = scheduler.id`
+			tq.joinArgs = []any{ftsQuery(f.terms)}
+			if ranked {
+				tq.score = `fts.score`
+			}
+		}
+	}
+	for _, term := range f.excluded {
+		if d.dialect == postgresDialect {
+			tq.where = append(tq.where, `NOT (`+document+` @@ to_tsquery('simple', ?))`)
+			tq.whereArgs = append(tq.whereArgs, tsQuery([]searchTerm{term}))
+		} else {
+			tq.where = append(tq.where, `id NOT IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)`)
+			tq.whereArgs = append(tq.whereArgs, ftsQuery([]searchTerm{term}))
 		}
-		task.ID = strconv.FormatInt(id, 10)
-		tasks = append(tasks, &task)
 	}
 
-	if err := rows.Err(); err != nil {
+	res, err := d.pageTasks(tq, page)
+	if err != nil {
 		return nil, err
 	}
-
-	if tasks == nil {
-		tasks = []*Task{}
+	if len(f.terms) > 0 {
+		withSnippets(res.Tasks, f.terms)
 	}
-
-	return tasks, nil
+	return res, nil
 }
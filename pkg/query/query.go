@@ -0,0 +1,281 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Условия запроса вида имя:значение
+const (
+	FieldFrom     = "from"     // from:01.11.2026 - не раньше даты
+	FieldTo       = "to"       // to:30.11.2026 - не позже даты
+	FieldDate     = "date"     // date:15.11.2026 - ровно на дату
+	FieldRepeat   = "repeat"   // repeat:yes или repeat:no - повторяющиеся или разовые задачи
+	FieldTag      = "tag"      // tag:work - задачи с тегом
+	FieldProject  = "project"  // project:12 - задачи проекта
+	FieldPriority = "priority" // priority:2 или priority:2,3 - задачи с одним из приоритетов
+)
+
+// DateFormat - формат дат в условиях запроса после разбора
+const DateFormat = "20060102"
+
+// Форматы, в которых даты указываются в запросе
+var dateLayouts = []string{"02.01.2006", DateFormat}
+
+// Query - разобранный поисковый запрос: задача подходит, если выполнены все условия
+type Query struct {
+	Nodes []Node
+}
+
+// Node - условие запроса
+type Node interface {
+	// Source возвращает фрагмент запроса, из которого получено условие
+	Source() Token
+}
+
+// Token - фрагмент исходной строки запроса
+type Token struct {
+	Text string
+	Pos  int // номер первого символа фрагмента, начиная с 1
+}
+
+// Text - слово или "фраза" для полнотекстового поиска
+type Text struct {
+	Src     Token
+	Text    string
+	Phrase  bool // фраза в кавычках: слова должны идти подряд
+	Prefix  bool // слово* - поиск по префиксу последнего слова
+	Negated bool // -слово - такого слова в задаче быть не должно
+}
+
+// DateCond - ограничение даты задачи
+type DateCond struct {
+	Src   Token
+	Field string // FieldFrom, FieldTo или FieldDate
+	Date  string // формат 20060102
+}
+
+// RepeatCond - отбор повторяющихся (Repeating) или разовых задач
+type RepeatCond struct {
+	Src       Token
+	Repeating bool
+}
+
+// TagCond - отбор задач с одним из тегов (или без них, если Negated)
+type TagCond struct {
+	Src     Token
+	Tags    []string
+	Negated bool
+}
+
+// ProjectCond - отбор задач проекта (или не из него, если Negated)
+type ProjectCond struct {
+	Src     Token
+	Project string // идентификатор проекта или none - задачи без проекта
+	Negated bool
+}
+
+// PriorityCond - отбор задач с одним из приоритетов (или без них, если Negated)
+type PriorityCond struct {
+	Src        Token
+	Priorities []int
+	Negated    bool
+}
+
+func (n *Text) Source() Token         { return n.Src }
+func (n *DateCond) Source() Token     { return n.Src }
+func (n *RepeatCond) Source() Token   { return n.Src }
+func (n *TagCond) Source() Token      { return n.Src }
+func (n *ProjectCond) Source() Token  { return n.Src }
+func (n *PriorityCond) Source() Token { return n.Src }
+
+// Error - ошибка в запросе с указанием фрагмента, в котором она найдена
+type Error struct {
+	Token Token
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ошибка в запросе на позиции %d (%s): %s", e.Token.Pos, e.Token.Text, e.Msg)
+}
+
+// Errorf возвращает ошибку во фрагменте tok
+func Errorf(tok Token, format string, args ...any) *Error {
+	return &Error{Token: tok, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Parse разбирает поисковый запрос. Элементы запроса разделяются пробелами:
+//   - слово, слово* (префикс) или "фраза в кавычках" - полнотекстовый поиск;
+//   - from:ДД.ММ.ГГГГ, to:ДД.ММ.ГГГГ, date:ДД.ММ.ГГГГ - ограничения даты;
+//   - repeat:yes или repeat:no - повторяющиеся или разовые задачи;
+//   - tag:имя, tag:"имя с пробелами" или tag:дом,дача - задачи с одним из тегов;
+//   - priority:2 или priority:2,3 - задачи с одним из приоритетов;
+//   - project:12 или project:none - задачи проекта или без проекта;
+//   - минус перед словом, фразой, тегом, приоритетом или проектом исключает их.
+func Parse(s string) (*Query, error) {
+	tokens, err := split(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, tok := range tokens {
+		node, err := parseToken(tok)
+		if err != nil {
+			return nil, err
+		}
+		q.Nodes = append(q.Nodes, node)
+	}
+	return q, nil
+}
+
+// split разбивает запрос на фрагменты по пробелам; пробелы внутри кавычек не разделяют фрагменты
+func split(s string) ([]Token, error) {
+	var tokens []Token
+	var cur []rune
+	start, quoteAt := 0, 0
+	inQuotes := false
+
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == '"':
+			if len(cur) == 0 {
+				start = i
+			}
+			if !inQuotes {
+				quoteAt = i
+			}
+			inQuotes = !inQuotes
+			cur = append(cur, r)
+		case unicode.IsSpace(r) && !inQuotes:
+			if len(cur) > 0 {
+				tokens = append(tokens, Token{Text: string(cur), Pos: start + 1})
+				cur = nil
+			}
+		default:
+			if len(cur) == 0 {
+				start = i
+			}
+			cur = append(cur, r)
+		}
+	}
+	if inQuotes {
+		return nil, Errorf(Token{Text: string(runes[quoteAt:]), Pos: quoteAt + 1}, "не закрыта кавычка")
+	}
+	if len(cur) > 0 {
+		tokens = append(tokens, Token{Text: string(cur), Pos: start + 1})
+	}
+	return tokens, nil
+}
+
+func parseToken(tok Token) (Node, error) {
+	text := tok.Text
+	negated := len(text) > 1 && text[0] == '-'
+	if negated {
+		text = text[1:]
+	}
+
+	// Фраза в кавычках
+	if text[0] == '"' {
+		body, prefix := strings.CutSuffix(text[1:], "*")
+		body, ok := strings.CutSuffix(body, `"`)
+		if !ok || strings.Contains(body, `"`) {
+			return nil, Errorf(tok, "после закрывающей кавычки ожидается пробел")
+		}
+		return &Text{Src: tok, Text: body, Phrase: true, Prefix: prefix, Negated: negated}, nil
+	}
+
+	// Условие имя:значение. Условием считаются только известные имена, поэтому "10:30",
+	// "TODO:" и "http://..." остаются текстом.
+	if name, value, ok := strings.Cut(text, ":"); ok && isFieldName(name) {
+		return parseField(tok, strings.ToLower(name), value, negated)
+	}
+
+	if strings.Contains(text, `"`) {
+		return nil, Errorf(tok, "кавычки допустимы только вокруг фразы или значения условия")
+	}
+	word, prefix := strings.CutSuffix(text, "*")
+	return &Text{Src: tok, Text: word, Prefix: prefix, Negated: negated}, nil
+}
+
+func parseField(tok Token, name, value string, negated bool) (Node, error) {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	if strings.Contains(value, `"`) {
+		return nil, Errorf(tok, "значение условия %s: нужно целиком заключить в кавычки", name)
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, Errorf(tok, "не указано значение условия %s:", name)
+	}
+
+	switch name {
+	case FieldFrom, FieldTo, FieldDate:
+		if negated {
+			return nil, Errorf(tok, "условие %s: нельзя исключить", name)
+		}
+		for _, layout := range dateLayouts {
+			if d, err := time.Parse(layout, value); err == nil {
+				return &DateCond{Src: tok, Field: name, Date: d.Format(DateFormat)}, nil
+			}
+		}
+		return nil, Errorf(tok, "неверная дата %q, ожидается ДД.ММ.ГГГГ", value)
+
+	case FieldRepeat:
+		var repeating bool
+		switch strings.ToLower(value) {
+		case "yes", "true", "да":
+			repeating = true
+		case "no", "false", "нет":
+			repeating = false
+		default:
+			return nil, Errorf(tok, "ожидается repeat:yes или repeat:no")
+		}
+		return &RepeatCond{Src: tok, Repeating: repeating != negated}, nil
+
+	case FieldTag:
+		tags := strings.Split(value, ",")
+		for i := range tags {
+			tags[i] = strings.TrimSpace(tags[i])
+		}
+		return &TagCond{Src: tok, Tags: tags, Negated: negated}, nil
+
+	case FieldProject:
+		return &ProjectCond{Src: tok, Project: strings.TrimSpace(value), Negated: negated}, nil
+
+	case FieldPriority:
+		priorities, err := ParsePriorities(value)
+		if err != nil {
+			return nil, Errorf(tok, "%s", err)
+		}
+		return &PriorityCond{Src: tok, Priorities: priorities, Negated: negated}, nil
+	}
+
+	return nil, Errorf(tok, "неизвестное условие %s:", name)
+}
+
+// isFieldName сообщает, что name - имя условия (без учёта регистра)
+func isFieldName(name string) bool {
+	switch strings.ToLower(name) {
+	case FieldFrom, FieldTo, FieldDate, FieldRepeat, FieldTag, FieldProject, FieldPriority:
+		return true
+	}
+	return false
+}
+
+// ParsePriorities разбирает список приоритетов через запятую: "2" или "2,3"
+func ParsePriorities(value string) ([]int, error) {
+	var priorities []int
+	for _, part := range strings.Split(value, ",") {
+		p, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("приоритет %q должен быть неотрицательным числом", strings.TrimSpace(part))
+		}
+		priorities = append(priorities, p)
+	}
+	return priorities, nil
+}
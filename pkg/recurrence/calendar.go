@@ -0,0 +1,228 @@
+package recurrence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Если за столько дней подряд не нашлось ни одного рабочего, календарь считаем некорректным
+const maxNonWorkingDays = 366
+
+// Calendar - производственный календарь: праздники и рабочие выходные (переносы).
+// Нулевой календарь (nil) считает нерабочими только субботу и воскресенье.
+type Calendar struct {
+	holidays map[time.Time]bool
+	workdays map[time.Time]bool
+}
+
+// calendarFile - формат JSON-файла календаря. Допускается и просто список праздников.
+type calendarFile struct {
+	Holidays []string `json:"holidays"`
+	Workdays []string `json:"workdays"`
+}
+
+// LoadCalendar загружает календарь из файла .json или .ics.
+// Если путь не указан, возвращается nil - календарь без праздников.
+func LoadCalendar(path string) (*Calendar, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return parseCalendarJSON(data)
+	case ".ics":
+		return parseCalendarICS(data)
+	}
+	return nil, fmt.Errorf("неподдерживаемый формат календаря: %s (ожидается .json или .ics)", path)
+}
+
+// NewCalendar создаёт календарь из списков праздников и рабочих выходных
+func NewCalendar(holidays, workdays []time.Time) *Calendar {
+	c := &Calendar{
+		holidays: make(map[time.Time]bool, len(holidays)),
+		workdays: make(map[time.Time]bool, len(workdays)),
+	}
+	for _, d := range holidays {
+		c.holidays[dateOf(d)] = true
+	}
+	for _, d := range workdays {
+		c.workdays[dateOf(d)] = true
+	}
+	return c
+}
+
+// IsWorkday сообщает, является ли день рабочим
+func (c *Calendar) IsWorkday(d time.Time) bool {
+	d = dateOf(d)
+	if c != nil {
+		if c.workdays[d] {
+			return true
+		}
+		if c.holidays[d] {
+			return false
+		}
+	}
+	return d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+}
+
+// shift переносит дату на ближайший рабочий день вперёд (dir > 0) или назад (dir < 0)
+func (c *Calendar) shift(d time.Time, dir int) (time.Time, error) {
+	for i := 0; i < maxNonWorkingDays; i++ {
+		if c.IsWorkday(d) {
+			return d, nil
+		}
+		d = d.AddDate(0, 0, dir)
+	}
+	return time.Time{}, ErrNoMatch
+}
+
+func parseCalendarJSON(data []byte) (*Calendar, error) {
+	var file calendarFile
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &file.Holidays); err != nil {
+			return nil, fmt.Errorf("ошибка разбора календаря: %v", err)
+		}
+	} else if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка разбора календаря: %v", err)
+	}
+
+	holidays, err := parseCalendarDates(file.Holidays)
+	if err != nil {
+		return nil, err
+	}
+	workdays, err := parseCalendarDates(file.Workdays)
+	if err != nil {
+		return nil, err
+	}
+	return NewCalendar(holidays, workdays), nil
+}
+
+func parseCalendarDates(list []string) ([]time.Time, error) {
+	res := make([]time.Time, 0, len(list))
+	for _, s := range list {
+		d, err := parseCalendarDate(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+// parseCalendarDate разбирает дату в формате 20060102 или 2006-01-02
+func parseCalendarDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{DateFormat, "2006-01-02"} {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверная дата в календаре: %q", s)
+}
+
+// parseCalendarICS читает праздники из событий VEVENT на целый день:
+// каждое событие делает нерабочими дни с DTSTART по DTEND (не включая DTEND)
+func parseCalendarICS(data []byte) (*Calendar, error) {
+	var holidays []time.Time
+	var start, end time.Time
+	inEvent := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Параметры свойства (DTSTART;VALUE=DATE) не нужны
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			inEvent = true
+			start, end = time.Time{}, time.Time{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if start.IsZero() {
+				return nil, errors.New("в событии календаря нет DTSTART")
+			}
+			if end.IsZero() || !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+				holidays = append(holidays, d)
+			}
+			inEvent = false
+		case inEvent && (name == "DTSTART" || name == "DTEND"):
+			if len(value) < len(DateFormat) {
+				return nil, fmt.Errorf("неверная дата в календаре: %q", value)
+			}
+			d, err := time.Parse(DateFormat, value[:len(DateFormat)])
+			if err != nil {
+				return nil, fmt.Errorf("неверная дата в календаре: %q", value)
+			}
+			if name == "DTSTART" {
+				start = d
+			} else {
+				end = d
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewCalendar(holidays, nil), nil
+}
+
+// shiftRule - модификатор "+bd"/"-bd": переносит даты правила с нерабочих дней
+type shiftRule struct {
+	rule Rule
+	dir  int
+}
+
+// cutShift отделяет модификатор "+bd"/"-bd" в конце правила
+func cutShift(repeat string) (string, int, bool) {
+	i := strings.LastIndexAny(repeat, " \t")
+	if i < 0 {
+		return repeat, 0, false
+	}
+	switch strings.ToLower(repeat[i+1:]) {
+	case "+bd":
+		return strings.TrimSpace(repeat[:i]), 1, true
+	case "-bd":
+		return strings.TrimSpace(repeat[:i]), -1, true
+	}
+	return repeat, 0, false
+}
+
+func (r shiftRule) next(start, after time.Time, cal *Calendar) (time.Time, error) {
+	// Перенос назад может дать дату не позже after - тогда берём следующую дату правила
+	for i := 0; i < legacySearchDays; i++ {
+		date, err := r.rule.next(start, after, cal)
+		if err != nil {
+			return time.Time{}, err
+		}
+		shifted, err := cal.shift(date, r.dir)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if shifted.After(after) {
+			return shifted, nil
+		}
+		after = date
+	}
+	return time.Time{}, ErrNoMatch
+}
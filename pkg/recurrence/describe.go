@@ -0,0 +1,357 @@
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Языки описаний правил
+const (
+	LangRu = "ru"
+	LangEn = "en"
+)
+
+type lang string
+
+func (l lang) pick(ru, en string) string {
+	if l == LangEn {
+		return en
+	}
+	return ru
+}
+
+// Describe возвращает описание правила повторения на языке lang (LangRu или LangEn,
+// при неизвестном языке - по-русски)
+func Describe(rule Rule, language string) string {
+	l := lang(LangRu)
+	if language == LangEn {
+		l = LangEn
+	}
+	return l.describe(rule)
+}
+
+func (l lang) describe(rule Rule) string {
+	switch r := rule.(type) {
+	case shiftRule:
+		if r.dir > 0 {
+			return l.describe(r.rule) + l.pick(", с переносом на следующий рабочий день",
+				", moved to the next working day if needed")
+		}
+		return l.describe(r.rule) + l.pick(", с переносом на предыдущий рабочий день",
+			", moved to the previous working day if needed")
+	case dayRule:
+		return l.every(r.days, unitDay)
+	case businessDayRule:
+		return l.every(r.days, unitWorkday)
+	case yearRule:
+		return l.every(1, unitYear)
+	case weekRule:
+		return l.every(r.interval, unitWeek) + " " + l.onWeekdays(isoWeekdays(r.weekdays))
+	case monthRule:
+		return l.inMonths(sortedKeys(r.months)) + " " + l.onMonthDays(sortedKeys(r.days))
+	case monthWeekdayRule:
+		wd := time.Weekday(r.weekday % 7)
+		items := make([]string, 0, len(r.nums))
+		for _, n := range sortedKeys(r.nums) {
+			items = append(items, l.ordinal(n, wd))
+		}
+		return l.inMonths(sortedKeys(r.months)) + l.pick(": ", " on the ") +
+			l.join(items) + " " + l.weekdayName(wd)
+	case *rrule:
+		return l.describeRRule(r)
+	}
+	return ""
+}
+
+func (l lang) describeRRule(r *rrule) string {
+	units := map[frequency]unit{daily: unitDay, weekly: unitWeek, monthly: unitMonth, yearly: unitYear}
+	res := l.every(r.interval, units[r.freq])
+
+	if len(r.byMonth) > 0 {
+		res += " " + l.inMonthList(sorted(r.byMonth))
+	}
+
+	if len(r.byDay) > 0 {
+		plain := true
+		for _, d := range r.byDay {
+			if d.n != 0 {
+				plain = false
+			}
+		}
+		if plain {
+			wds := make([]time.Weekday, 0, len(r.byDay))
+			for _, d := range r.byDay {
+				wds = append(wds, d.wd)
+			}
+			res += " " + l.onWeekdays(wds)
+		} else {
+			items := make([]string, 0, len(r.byDay))
+			for _, d := range r.byDay {
+				if d.n == 0 {
+					items = append(items, l.pick(everyRu[weekdayGender[d.wd]], "every")+" "+l.weekdayName(d.wd))
+				} else {
+					items = append(items, l.ordinal(d.n, d.wd)+" "+l.weekdayName(d.wd))
+				}
+			}
+			res += l.pick(": ", " on the ") + l.join(items)
+		}
+	}
+
+	if len(r.byMonthDay) > 0 {
+		res += " " + l.onMonthDays(sortMonthDays(r.byMonthDay))
+	}
+
+	if len(r.bySetPos) > 0 {
+		switch {
+		case len(r.bySetPos) == 1 && r.bySetPos[0] == 1:
+			res += l.pick(" (только первая подходящая дата периода)", " (only the first matching date of each period)")
+		case len(r.bySetPos) == 1 && r.bySetPos[0] == -1:
+			res += l.pick(" (только последняя подходящая дата периода)", " (only the last matching date of each period)")
+		default:
+			pos := make([]string, 0, len(r.bySetPos))
+			for _, p := range r.bySetPos {
+				pos = append(pos, strconv.Itoa(p))
+			}
+			res += l.pick(" (позиции в периоде: ", " (positions within each period: ") + strings.Join(pos, ", ") + ")"
+		}
+	}
+
+	if r.count > 0 {
+		res += fmt.Sprintf(l.pick(", всего %d %s", ", %d %s"), r.count,
+			l.pick(pluralRu(r.count, "раз", "раза", "раз"), pluralEn(r.count, "time", "times")))
+	}
+	if !r.until.IsZero() {
+		res += l.pick(", до ", ", until ") + r.until.Format(l.pick("02.01.2006", "2006-01-02"))
+	}
+
+	return res
+}
+
+// unit - единица интервала с формами для "каждый N ..."
+type unit struct {
+	ru     [3]string // 1, 2-4, 5+ (день, дня, дней)
+	gender int
+	en     [2]string
+}
+
+// Род в русском языке
+const (
+	masculine = iota
+	feminine
+	neuter
+)
+
+var (
+	unitDay     = unit{ru: [3]string{"день", "дня", "дней"}, gender: masculine, en: [2]string{"day", "days"}}
+	unitWorkday = unit{ru: [3]string{"рабочий день", "рабочих дня", "рабочих дней"}, gender: masculine, en: [2]string{"working day", "working days"}}
+	unitWeek    = unit{ru: [3]string{"неделю", "недели", "недель"}, gender: feminine, en: [2]string{"week", "weeks"}}
+	unitMonth   = unit{ru: [3]string{"месяц", "месяца", "месяцев"}, gender: masculine, en: [2]string{"month", "months"}}
+	unitYear    = unit{ru: [3]string{"год", "года", "лет"}, gender: masculine, en: [2]string{"year", "years"}}
+)
+
+// "каждый" в винительном падеже по родам
+var everyRu = [3]string{"каждый", "каждую", "каждое"}
+
+// every - "каждый день", "каждые 3 недели", "every 2 months"
+func (l lang) every(n int, u unit) string {
+	if l == LangEn {
+		if n == 1 {
+			return "every " + u.en[0]
+		}
+		return fmt.Sprintf("every %d %s", n, u.en[1])
+	}
+
+	if n == 1 {
+		return everyRu[u.gender] + " " + u.ru[0]
+	}
+	word := pluralRu(n, u.ru[0], u.ru[1], u.ru[2])
+	if n%10 == 1 && n%100 != 11 {
+		return fmt.Sprintf("%s %d %s", everyRu[u.gender], n, word)
+	}
+	return fmt.Sprintf("каждые %d %s", n, word)
+}
+
+var weekdaysRu = map[time.Weekday]string{
+	time.Monday: "понедельник", time.Tuesday: "вторник", time.Wednesday: "среда", time.Thursday: "четверг",
+	time.Friday: "пятница", time.Saturday: "суббота", time.Sunday: "воскресенье",
+}
+
+// Дни недели в дательном падеже множественного числа: "по понедельникам"
+var weekdaysRuDative = map[time.Weekday]string{
+	time.Monday: "понедельникам", time.Tuesday: "вторникам", time.Wednesday: "средам", time.Thursday: "четвергам",
+	time.Friday: "пятницам", time.Saturday: "субботам", time.Sunday: "воскресеньям",
+}
+
+var weekdayGender = map[time.Weekday]int{
+	time.Monday: masculine, time.Tuesday: masculine, time.Wednesday: feminine, time.Thursday: masculine,
+	time.Friday: feminine, time.Saturday: feminine, time.Sunday: neuter,
+}
+
+var monthsRuLocative = [...]string{"", "январе", "феврале", "марте", "апреле", "мае", "июне",
+	"июле", "августе", "сентябре", "октябре", "ноябре", "декабре"}
+
+func (l lang) weekdayName(wd time.Weekday) string {
+	return l.pick(weekdaysRu[wd], wd.String())
+}
+
+// onWeekdays - "по понедельникам и четвергам", "on Mondays and Thursdays"
+func (l lang) onWeekdays(wds []time.Weekday) string {
+	sort.Slice(wds, func(i, j int) bool { return isoWeekday(wds[i]) < isoWeekday(wds[j]) })
+	items := make([]string, 0, len(wds))
+	for _, wd := range wds {
+		items = append(items, l.pick(weekdaysRuDative[wd], wd.String()+"s"))
+	}
+	return l.pick("по ", "on ") + l.join(items)
+}
+
+// inMonths - "каждый месяц" или "в феврале и августе"
+func (l lang) inMonths(months []int) string {
+	if len(months) == 12 {
+		return l.every(1, unitMonth)
+	}
+	return l.inMonthList(months)
+}
+
+func (l lang) inMonthList(months []int) string {
+	items := make([]string, 0, len(months))
+	for _, m := range months {
+		items = append(items, l.pick(monthsRuLocative[m], time.Month(m).String()))
+	}
+	return l.pick("в ", "in ") + l.join(items)
+}
+
+// onMonthDays - "1-го и последнего числа", "on the 1st and last day"
+func (l lang) onMonthDays(days []int) string {
+	days = sortMonthDays(days)
+	items := make([]string, 0, len(days))
+	for _, d := range days {
+		switch {
+		case d == -1:
+			items = append(items, l.pick("последнего", "last"))
+		case d == -2:
+			items = append(items, l.pick("предпоследнего", "second to last"))
+		case d < 0:
+			items = append(items, l.pick(fmt.Sprintf("%d-го с конца", -d), ordinalEn(-d)+" to last"))
+		default:
+			items = append(items, l.pick(fmt.Sprintf("%d-го", d), ordinalEn(d)))
+		}
+	}
+	return l.pick("", "on the ") + l.join(items) + l.pick(" числа", " day")
+}
+
+// ordinal - порядковое числительное для n-го дня недели: "вторая", "последний", "second"
+func (l lang) ordinal(n int, wd time.Weekday) string {
+	if l == LangEn {
+		switch {
+		case n == -1:
+			return "last"
+		case n == -2:
+			return "second to last"
+		case n < 0:
+			return ordinalEn(-n) + " to last"
+		case n <= 5:
+			return [...]string{"", "first", "second", "third", "fourth", "fifth"}[n]
+		}
+		return ordinalEn(n)
+	}
+
+	g := weekdayGender[wd]
+	forms := map[int][3]string{
+		1:  {"первый", "первая", "первое"},
+		2:  {"второй", "вторая", "второе"},
+		3:  {"третий", "третья", "третье"},
+		4:  {"четвёртый", "четвёртая", "четвёртое"},
+		5:  {"пятый", "пятая", "пятое"},
+		-1: {"последний", "последняя", "последнее"},
+		-2: {"предпоследний", "предпоследняя", "предпоследнее"},
+	}
+	if f, ok := forms[n]; ok {
+		return f[g]
+	}
+	suffix := [3]string{"-й", "-я", "-е"}[g]
+	if n < 0 {
+		return strconv.Itoa(-n) + suffix + " с конца"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// join соединяет элементы списка: "a, b и c", "a, b and c"
+func (l lang) join(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + l.pick(" и ", " and ") + items[len(items)-1]
+}
+
+func pluralRu(n int, one, few, many string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return few
+	}
+	return many
+}
+
+func pluralEn(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func ordinalEn(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return sorted(keys)
+}
+
+func sorted(list []int) []int {
+	res := append([]int(nil), list...)
+	sort.Ints(res)
+	return res
+}
+
+// sortMonthDays упорядочивает дни месяца: сначала с начала месяца, затем с конца (-1, -2, ...)
+func sortMonthDays(days []int) []int {
+	res := append([]int(nil), days...)
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if (a > 0) != (b > 0) {
+			return a > 0
+		}
+		if a > 0 {
+			return a < b
+		}
+		return a > b
+	})
+	return res
+}
+
+func isoWeekdays(m map[int]bool) []time.Weekday {
+	res := make([]time.Weekday, 0, len(m))
+	for _, n := range sortedKeys(m) {
+		res = append(res, time.Weekday(n%7))
+	}
+	return res
+}
@@ -0,0 +1,266 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Максимальный период поиска даты для правил w и m (8 лет - с запасом на 29 февраля)
+const legacySearchDays = 8 * 366
+
+// dayRule - правило "d N": каждые N дней
+type dayRule struct {
+	days int
+}
+
+// businessDayRule - правило "bd N": каждые N рабочих дней
+type businessDayRule struct {
+	days int
+}
+
+// yearRule - правило "y": ежегодно
+type yearRule struct{}
+
+// weekRule - правило "w 1,2,... [N]": по указанным дням недели (1 - понедельник,
+// 7 - воскресенье) каждую N-ю неделю, считая от недели начала серии
+type weekRule struct {
+	weekdays map[int]bool
+	interval int
+}
+
+// monthRule - правило "m 1,-1 [1,...]": по дням месяца, опционально в указанных месяцах
+type monthRule struct {
+	days   map[int]bool
+	months map[int]bool
+}
+
+// monthWeekdayRule - правило "wm 5 -1 [1,...]": по N-му дню недели месяца
+// (1..5, -1 - последний, -2 - предпоследний), опционально в указанных месяцах
+type monthWeekdayRule struct {
+	weekday int
+	nums    map[int]bool
+	months  map[int]bool
+}
+
+func parseLegacy(repeat string) (Rule, error) {
+	parts := strings.Fields(repeat)
+	if len(parts) == 0 {
+		return nil, errors.New("неверный формат repeat")
+	}
+
+	switch parts[0] {
+	case "d":
+		if len(parts) != 2 {
+			return nil, errors.New("для правила d нужно указать интервал в днях")
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days < 1 || days > 400 {
+			return nil, errors.New("недопустимый интервал для d")
+		}
+		return dayRule{days: days}, nil
+
+	case "bd":
+		if len(parts) != 2 {
+			return nil, errors.New("для правила bd нужно указать интервал в рабочих днях")
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days < 1 || days > 400 {
+			return nil, errors.New("недопустимый интервал для bd")
+		}
+		return businessDayRule{days: days}, nil
+
+	case "y":
+		return yearRule{}, nil
+
+	case "w":
+		if len(parts) != 2 && len(parts) != 3 {
+			return nil, errors.New("для правила w нужно указать дни недели через запятую")
+		}
+		weekdays := make(map[int]bool)
+		for _, s := range strings.Split(parts[1], ",") {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > 7 {
+				return nil, fmt.Errorf("день недели должен быть от 1 до 7: %s", s)
+			}
+			weekdays[n] = true
+		}
+		interval := 1
+		if len(parts) == 3 {
+			var err error
+			interval, err = strconv.Atoi(parts[2])
+			if err != nil || interval < 1 || interval > 52 {
+				return nil, fmt.Errorf("интервал в неделях должен быть от 1 до 52: %s", parts[2])
+			}
+		}
+		return weekRule{weekdays: weekdays, interval: interval}, nil
+
+	case "m":
+		if len(parts) < 2 {
+			return nil, errors.New("для правила m нужно указать дни месяца")
+		}
+		days := make(map[int]bool)
+		for _, s := range strings.Split(parts[1], ",") {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < -2 || n > 31 || n == 0 {
+				return nil, fmt.Errorf("день месяца должен быть от 1 до 31 или -1, -2: %s", s)
+			}
+			days[n] = true
+		}
+
+		months, err := parseMonths(parts, 2)
+		if err != nil {
+			return nil, err
+		}
+		return monthRule{days: days, months: months}, nil
+
+	case "wm":
+		if len(parts) < 3 {
+			return nil, errors.New("для правила wm нужно указать день недели и его номер в месяце")
+		}
+		weekday, err := strconv.Atoi(parts[1])
+		if err != nil || weekday < 1 || weekday > 7 {
+			return nil, fmt.Errorf("день недели должен быть от 1 до 7: %s", parts[1])
+		}
+		nums := make(map[int]bool)
+		for _, s := range strings.Split(parts[2], ",") {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < -2 || n > 5 || n == 0 {
+				return nil, fmt.Errorf("номер дня недели в месяце должен быть от 1 до 5 или -1, -2: %s", s)
+			}
+			nums[n] = true
+		}
+		months, err := parseMonths(parts, 3)
+		if err != nil {
+			return nil, err
+		}
+		return monthWeekdayRule{weekday: weekday, nums: nums, months: months}, nil
+	}
+
+	return nil, fmt.Errorf("неподдерживаемый формат repeat: %s", repeat)
+}
+
+// parseMonths разбирает необязательный список месяцев в parts[i];
+// если он не указан, подходят все месяцы
+func parseMonths(parts []string, i int) (map[int]bool, error) {
+	months := make(map[int]bool)
+	if len(parts) > i {
+		for _, s := range strings.Split(parts[i], ",") {
+			m, err := strconv.Atoi(s)
+			if err != nil || m < 1 || m > 12 {
+				return nil, fmt.Errorf("месяц должен быть от 1 до 12: %s", s)
+			}
+			months[m] = true
+		}
+	} else {
+		for m := 1; m <= 12; m++ {
+			months[m] = true
+		}
+	}
+	return months, nil
+}
+
+func (r dayRule) next(start, after time.Time, _ *Calendar) (time.Time, error) {
+	date := start
+	for {
+		date = date.AddDate(0, 0, r.days)
+		if date.After(after) {
+			return date, nil
+		}
+	}
+}
+
+func (r businessDayRule) next(start, after time.Time, cal *Calendar) (time.Time, error) {
+	date := start
+	for {
+		skipped := 0
+		for n := 0; n < r.days; {
+			date = date.AddDate(0, 0, 1)
+			if cal.IsWorkday(date) {
+				n++
+				skipped = 0
+			} else if skipped++; skipped > maxNonWorkingDays {
+				return time.Time{}, ErrNoMatch
+			}
+		}
+		if date.After(after) {
+			return date, nil
+		}
+	}
+}
+
+func (r yearRule) next(start, after time.Time, _ *Calendar) (time.Time, error) {
+	date := start
+	for {
+		date = date.AddDate(1, 0, 0)
+		if date.After(after) {
+			return date, nil
+		}
+	}
+}
+
+func (r weekRule) next(start, after time.Time, _ *Calendar) (time.Time, error) {
+	// Чётность недель считаем от недели начала серии, а не от текущей даты,
+	// чтобы выполнение задачи с опозданием не сдвигало расписание
+	anchor := mondayOf(start)
+	current := after
+	for day := 0; day < legacySearchDays; day++ {
+		current = current.AddDate(0, 0, 1)
+		weeks := int(mondayOf(current).Sub(anchor).Hours()) / (24 * 7)
+		if r.weekdays[isoWeekday(current.Weekday())] && weeks%r.interval == 0 {
+			return current, nil
+		}
+	}
+	return time.Time{}, ErrNoMatch
+}
+
+func (r monthRule) next(start, after time.Time, _ *Calendar) (time.Time, error) {
+	current := after
+	for day := 0; day < legacySearchDays; day++ {
+		current = current.AddDate(0, 0, 1)
+		currentDay := current.Day()
+		lastDay := daysIn(current.Year(), current.Month())
+
+		dayMatch := r.days[currentDay] ||
+			(r.days[-1] && currentDay == lastDay) ||
+			(r.days[-2] && currentDay == lastDay-1)
+
+		if dayMatch && r.months[int(current.Month())] {
+			return current, nil
+		}
+	}
+	return time.Time{}, ErrNoMatch
+}
+
+func (r monthWeekdayRule) next(start, after time.Time, _ *Calendar) (time.Time, error) {
+	current := after
+	for day := 0; day < legacySearchDays; day++ {
+		current = current.AddDate(0, 0, 1)
+		if isoWeekday(current.Weekday()) != r.weekday || !r.months[int(current.Month())] {
+			continue
+		}
+
+		// номер этого дня недели в месяце с начала и с конца
+		fromStart := (current.Day()-1)/7 + 1
+		fromEnd := -((daysIn(current.Year(), current.Month())-current.Day())/7 + 1)
+		if r.nums[fromStart] || r.nums[fromEnd] {
+			return current, nil
+		}
+	}
+	return time.Time{}, ErrNoMatch
+}
+
+// mondayOf возвращает понедельник недели, в которую попадает дата
+func mondayOf(t time.Time) time.Time {
+	return t.AddDate(0, 0, 1-isoWeekday(t.Weekday()))
+}
+
+// isoWeekday переводит день недели Go (воскресенье = 0) в нумерацию правил (воскресенье = 7)
+func isoWeekday(wd time.Weekday) int {
+	if wd == time.Sunday {
+		return 7
+	}
+	return int(wd)
+}
@@ -0,0 +1,254 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DateFormat = "20060102"
+
+var (
+	// ErrEmptyRule - правило повторения не указано
+	ErrEmptyRule = errors.New("не указано правило повторения")
+	// ErrNoMatch - правило не даёт ни одной подходящей даты
+	ErrNoMatch = errors.New("не найдена подходящая дата")
+	// ErrFinished - серия повторений завершена (COUNT/UNTIL)
+	ErrFinished = errors.New("повторения задачи завершены")
+)
+
+// Rule - разобранное правило повторения
+type Rule interface {
+	// next возвращает первую дату серии, начатой в start, строго после after.
+	// Обе даты передаются без времени (полночь UTC), after >= start.
+	// Календарь нужен правилам, учитывающим рабочие дни, и может быть nil.
+	next(start, after time.Time, cal *Calendar) (time.Time, error)
+}
+
+// Parse разбирает правило повторения: RRULE по RFC 5545 (с префиксом "RRULE:")
+// или одно из собственных правил d, bd, w, wm, m, y. Правило может заканчиваться
+// модификатором "+bd" или "-bd", который переносит даты с нерабочих дней
+// на ближайший следующий или предыдущий рабочий день.
+func Parse(repeat string) (Rule, error) {
+	repeat = strings.TrimSpace(repeat)
+	if repeat == "" {
+		return nil, ErrEmptyRule
+	}
+
+	if base, dir, ok := cutShift(repeat); ok {
+		rule, err := Parse(base)
+		if err != nil {
+			return nil, err
+		}
+		if _, nested := rule.(shiftRule); nested {
+			return nil, errors.New("модификатор +bd/-bd указывается только один раз")
+		}
+		return shiftRule{rule: rule, dir: dir}, nil
+	}
+
+	if isRRule(repeat) {
+		return parseRRule(repeat)
+	}
+	return parseLegacy(repeat)
+}
+
+// Next возвращает ближайшую после now дату серии, начатой в start.
+// Дата start сама по себе не возвращается, даже если она позже now.
+func Next(rule Rule, start, now time.Time) (time.Time, error) {
+	return Series{Rule: rule, Start: start}.Next(now)
+}
+
+// End - условия окончания серии, которые хранятся вместе с задачей
+type End struct {
+	Count int       // сколько раз всего нужно выполнить задачу, 0 - без ограничения
+	Done  int       // сколько раз задача уже выполнена
+	Until time.Time // последняя допустимая дата, нулевая - без ограничения
+}
+
+// Anchor - от какой даты отсчитывается следующее повторение после выполнения задачи
+type Anchor int
+
+const (
+	// AnchorSchedule - от начала серии, по расписанию
+	AnchorSchedule Anchor = iota
+	// AnchorCompletion - от фактической даты выполнения
+	AnchorCompletion
+)
+
+// Series - серия повторений задачи: правило, дата начала серии, текущая дата задачи,
+// условия окончания, даты-исключения (EXDATE), календарь рабочих дней
+// и режим отсчёта повторений
+type Series struct {
+	Rule     Rule
+	Start    time.Time
+	Date     time.Time // текущая дата задачи, нулевая - совпадает со Start
+	End      End
+	Except   []time.Time
+	Calendar *Calendar
+	Anchor   Anchor
+}
+
+// Next возвращает ближайшую после now и после текущей даты задачи дату серии,
+// пропуская даты-исключения, или ErrFinished, если дат до End.Until больше нет
+func (s Series) Next(now time.Time) (time.Time, error) {
+	except := make(map[time.Time]bool, len(s.Except))
+	for _, d := range s.Except {
+		except[dateOf(d)] = true
+	}
+
+	start := s.Origin()
+	after := dateOf(now)
+	for _, d := range []time.Time{start, s.Date} {
+		if !d.IsZero() && after.Before(dateOf(d)) {
+			after = dateOf(d)
+		}
+	}
+
+	next, err := s.Rule.next(start, after, s.Calendar)
+	for err == nil && except[next] {
+		next, err = s.Rule.next(start, next, s.Calendar)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !s.End.Until.IsZero() && next.After(dateOf(s.End.Until)) {
+		return time.Time{}, ErrFinished
+	}
+	return next, nil
+}
+
+// Origin возвращает дату, от которой отсчитываются повторения: начало серии, если текущая
+// дата задачи попадает в его сетку, иначе (дату задачи перенесли) - текущую дату задачи
+func (s Series) Origin() time.Time {
+	start := dateOf(s.Start)
+	if s.Date.IsZero() {
+		return start
+	}
+	date := dateOf(s.Date)
+	if !start.Before(date) {
+		return date
+	}
+	if d, err := s.Rule.next(start, date.AddDate(0, 0, -1), s.Calendar); err == nil && d.Equal(date) {
+		return start
+	}
+	return date
+}
+
+// Done возвращает дату, на которую переносится задача после выполнения в день now,
+// или ErrFinished, если это выполнение было последним. При AnchorCompletion
+// серия начинается заново с даты выполнения.
+func (s Series) Done(now time.Time) (time.Time, error) {
+	if s.End.Count > 0 && s.End.Done+1 >= s.End.Count {
+		return time.Time{}, ErrFinished
+	}
+	if s.Anchor == AnchorCompletion {
+		s.Start = dateOf(now)
+		s.Date = time.Time{}
+	}
+	return s.Next(now)
+}
+
+// Occurrences возвращает до n ближайших после now дат серии. Если серия
+// завершается раньше (COUNT, UNTIL), дат будет меньше.
+func (s Series) Occurrences(now time.Time, n int) ([]time.Time, error) {
+	s.Start = s.Origin()
+	var dates []time.Time
+	for len(dates) < n {
+		next, err := s.Next(now)
+		if errors.Is(err, ErrFinished) {
+			break
+		}
+		if err != nil {
+			if len(dates) == 0 {
+				return nil, err
+			}
+			break
+		}
+		dates = append(dates, next)
+		s.Date = next
+	}
+	return dates, nil
+}
+
+// Bounds возвращает условия окончания, заданные в самом правиле (COUNT и UNTIL в RRULE)
+func Bounds(rule Rule) (count int, until time.Time) {
+	if r, ok := rule.(shiftRule); ok {
+		rule = r.rule
+	}
+	if r, ok := rule.(*rrule); ok {
+		return r.count, r.until
+	}
+	return 0, time.Time{}
+}
+
+// NextDate - строковая обёртка над Parse и Next, даты в формате DateFormat
+func NextDate(now time.Time, dstart string, repeat string) (string, error) {
+	return NextDateIn(nil, now, dstart, repeat)
+}
+
+// NextDateIn - то же, что NextDate, но рабочие дни определяются календарём cal
+func NextDateIn(cal *Calendar, now time.Time, dstart string, repeat string) (string, error) {
+	if strings.TrimSpace(repeat) == "" {
+		return "", ErrEmptyRule
+	}
+
+	start, err := time.Parse(DateFormat, dstart)
+	if err != nil {
+		return "", fmt.Errorf("не удалось распарсить dstart: %v", err)
+	}
+
+	rule, err := Parse(repeat)
+	if err != nil {
+		return "", err
+	}
+
+	next, err := Series{Rule: rule, Start: start, Calendar: cal}.Next(now)
+	if err != nil {
+		return "", err
+	}
+	return next.Format(DateFormat), nil
+}
+
+// NextDatesIn возвращает до n ближайших после now дат правила repeat,
+// начиная с dstart, в формате DateFormat
+func NextDatesIn(cal *Calendar, now time.Time, dstart string, repeat string, n int) ([]string, error) {
+	if strings.TrimSpace(repeat) == "" {
+		return nil, ErrEmptyRule
+	}
+
+	start, err := time.Parse(DateFormat, dstart)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось распарсить dstart: %v", err)
+	}
+
+	rule, err := Parse(repeat)
+	if err != nil {
+		return nil, err
+	}
+
+	dates, err := Series{Rule: rule, Start: start, Calendar: cal}.Occurrences(now, n)
+	if errors.Is(err, ErrNoMatch) {
+		return nil, fmt.Errorf("правило %q не даёт ни одной даты: %w", repeat, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(dates))
+	for _, d := range dates {
+		res = append(res, d.Format(DateFormat))
+	}
+	return res, nil
+}
+
+// dateOf отбрасывает время, оставляя календарную дату в UTC
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysIn возвращает количество дней в месяце
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,388 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const rrulePrefix = "RRULE:"
+
+// Если правило не дало ни одной даты за столько периодов подряд
+// и за столько лет, считаем, что подходящих дат нет
+const (
+	maxEmptyPeriods = 100
+	maxEmptyYears   = 30
+)
+
+type frequency int
+
+const (
+	daily frequency = iota
+	weekly
+	monthly
+	yearly
+)
+
+var frequencies = map[string]frequency{
+	"DAILY":   daily,
+	"WEEKLY":  weekly,
+	"MONTHLY": monthly,
+	"YEARLY":  yearly,
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// weekdayNum - элемент BYDAY: день недели с необязательным порядковым номером (2TU, -1FR)
+type weekdayNum struct {
+	n  int
+	wd time.Weekday
+}
+
+// rrule - правило повторения по RFC 5545 (только даты, без времени)
+type rrule struct {
+	freq       frequency
+	interval   int
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+	count      int
+	until      time.Time
+	wkst       time.Weekday
+}
+
+func isRRule(repeat string) bool {
+	return len(repeat) >= len(rrulePrefix) && strings.EqualFold(repeat[:len(rrulePrefix)], rrulePrefix)
+}
+
+func parseRRule(repeat string) (Rule, error) {
+	r := &rrule{interval: 1, wkst: time.Monday}
+	freqSet := false
+
+	body := strings.TrimSpace(repeat[len(rrulePrefix):])
+	if body == "" {
+		return nil, errors.New("пустое правило RRULE")
+	}
+
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(body, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("неверный параметр RRULE: %s", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("параметр %s указан несколько раз", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			r.freq, ok = frequencies[value]
+			if !ok {
+				return nil, fmt.Errorf("неподдерживаемое значение FREQ: %s", value)
+			}
+			freqSet = true
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err != nil || r.interval < 1 || r.interval > 1000 {
+				return nil, fmt.Errorf("INTERVAL должен быть от 1 до 1000: %s", value)
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(value)
+			if err != nil || r.count < 1 {
+				return nil, fmt.Errorf("COUNT должен быть положительным числом: %s", value)
+			}
+		case "UNTIL":
+			r.until, err = parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+		case "BYDAY":
+			r.byDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseIntList(key, value, 31, false)
+		case "BYMONTH":
+			r.byMonth, err = parseIntList(key, value, 12, true)
+		case "BYSETPOS":
+			r.bySetPos, err = parseIntList(key, value, 366, false)
+		case "WKST":
+			r.wkst, ok = weekdays[value]
+			if !ok {
+				return nil, fmt.Errorf("неверный день недели в WKST: %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("неподдерживаемый параметр RRULE: %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !freqSet {
+		return nil, errors.New("в RRULE не указан FREQ")
+	}
+	if r.count > 0 && !r.until.IsZero() {
+		return nil, errors.New("COUNT и UNTIL нельзя указывать одновременно")
+	}
+	if len(r.bySetPos) > 0 && len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byMonth) == 0 {
+		return nil, errors.New("BYSETPOS используется только вместе с BYDAY, BYMONTHDAY или BYMONTH")
+	}
+	if r.freq == weekly && len(r.byMonthDay) > 0 {
+		return nil, errors.New("BYMONTHDAY не используется с FREQ=WEEKLY")
+	}
+	for _, d := range r.byDay {
+		if d.n == 0 {
+			continue
+		}
+		switch {
+		case r.freq != monthly && r.freq != yearly:
+			return nil, errors.New("порядковый номер в BYDAY допустим только для FREQ=MONTHLY и FREQ=YEARLY")
+		case r.freq == monthly && (d.n < -5 || d.n > 5):
+			return nil, fmt.Errorf("для FREQ=MONTHLY номер дня недели должен быть от -5 до 5: %d", d.n)
+		}
+	}
+
+	return r, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{DateFormat, "20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return dateOf(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверный формат UNTIL: %s", value)
+}
+
+func parseByDay(value string) ([]weekdayNum, error) {
+	var res []weekdayNum
+	for _, s := range strings.Split(value, ",") {
+		if len(s) < 2 {
+			return nil, fmt.Errorf("неверное значение BYDAY: %s", s)
+		}
+		wd, ok := weekdays[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("неверное значение BYDAY: %s", s)
+		}
+		var n int
+		if num := s[:len(s)-2]; num != "" {
+			var err error
+			n, err = strconv.Atoi(num)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("неверное значение BYDAY: %s", s)
+			}
+		}
+		res = append(res, weekdayNum{n: n, wd: wd})
+	}
+	return res, nil
+}
+
+// parseIntList разбирает список чисел от -limit до limit без нуля
+// (или от 1 до limit, если positive)
+func parseIntList(key, value string, limit int, positive bool) ([]int, error) {
+	var res []int
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n == 0 || n > limit || n < -limit || (positive && n < 0) {
+			return nil, fmt.Errorf("недопустимое значение %s: %s", key, s)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func (r *rrule) next(start, after time.Time, _ *Calendar) (time.Time, error) {
+	eff := r.withDefaults(start)
+
+	n := 0
+	empty := 0
+	lastHit := start
+	for p := 0; ; p++ {
+		from, to := eff.period(start, p)
+		set := eff.expand(from, to)
+
+		if len(set) == 0 {
+			empty++
+			if empty > maxEmptyPeriods && from.After(lastHit.AddDate(maxEmptyYears, 0, 0)) {
+				return time.Time{}, ErrNoMatch
+			}
+			continue
+		}
+		empty = 0
+
+		for _, d := range set {
+			if d.Before(start) {
+				continue
+			}
+			if !r.until.IsZero() && d.After(r.until) {
+				return time.Time{}, ErrFinished
+			}
+			n++
+			if r.count > 0 && n > r.count {
+				return time.Time{}, ErrFinished
+			}
+			lastHit = d
+			if d.After(after) {
+				return d, nil
+			}
+		}
+	}
+}
+
+// withDefaults подставляет значения BYxxx, которые по RFC 5545 берутся из даты начала
+func (r *rrule) withDefaults(start time.Time) *rrule {
+	eff := *r
+	switch r.freq {
+	case weekly:
+		if len(eff.byDay) == 0 {
+			eff.byDay = []weekdayNum{{wd: start.Weekday()}}
+		}
+	case monthly:
+		if len(eff.byDay) == 0 && len(eff.byMonthDay) == 0 {
+			eff.byMonthDay = []int{start.Day()}
+		}
+	case yearly:
+		if len(eff.byDay) == 0 && len(eff.byMonthDay) == 0 {
+			if len(eff.byMonth) == 0 {
+				eff.byMonth = []int{int(start.Month())}
+			}
+			eff.byMonthDay = []int{start.Day()}
+		}
+	}
+	return &eff
+}
+
+// period возвращает границы [from, to) p-го периода серии
+func (r *rrule) period(start time.Time, p int) (time.Time, time.Time) {
+	step := p * r.interval
+	switch r.freq {
+	case weekly:
+		offset := (int(start.Weekday()) - int(r.wkst) + 7) % 7
+		from := start.AddDate(0, 0, step*7-offset)
+		return from, from.AddDate(0, 0, 7)
+	case monthly:
+		from := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, 0)
+	case yearly:
+		from := time.Date(start.Year()+step, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, 0)
+	default:
+		from := start.AddDate(0, 0, step)
+		return from, from.AddDate(0, 0, 1)
+	}
+}
+
+// expand возвращает отсортированные даты периода, подходящие под правило, с учётом BYSETPOS
+func (r *rrule) expand(from, to time.Time) []time.Time {
+	var set []time.Time
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		if r.match(d) {
+			set = append(set, d)
+		}
+	}
+
+	if len(r.bySetPos) == 0 || len(set) == 0 {
+		return set
+	}
+
+	picked := make(map[int]bool)
+	for _, pos := range r.bySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(set) + pos
+		}
+		if i >= 0 && i < len(set) {
+			picked[i] = true
+		}
+	}
+	idx := make([]int, 0, len(picked))
+	for i := range picked {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+
+	res := make([]time.Time, 0, len(idx))
+	for _, i := range idx {
+		res = append(res, set[i])
+	}
+	return res
+}
+
+func (r *rrule) match(d time.Time) bool {
+	if len(r.byMonth) > 0 && !containsInt(r.byMonth, int(d.Month())) {
+		return false
+	}
+
+	if len(r.byMonthDay) > 0 {
+		last := daysIn(d.Year(), d.Month())
+		ok := false
+		for _, md := range r.byMonthDay {
+			if md == d.Day() || (md < 0 && last+1+md == d.Day()) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(r.byDay) > 0 {
+		ok := false
+		for _, bd := range r.byDay {
+			if bd.wd == d.Weekday() && (bd.n == 0 || r.ordinalMatch(d, bd.n)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ordinalMatch проверяет, что d - n-й (с конца при n < 0) такой день недели
+// в месяце (FREQ=MONTHLY или YEARLY с BYMONTH) или в году
+func (r *rrule) ordinalMatch(d time.Time, n int) bool {
+	var index, total int
+	if r.freq == monthly || len(r.byMonth) > 0 {
+		index, total = d.Day(), daysIn(d.Year(), d.Month())
+	} else {
+		index = d.YearDay()
+		total = time.Date(d.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+
+	if n > 0 {
+		return (index-1)/7+1 == n
+	}
+	return (total-index)/7+1 == -n
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
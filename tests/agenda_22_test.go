@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreRange(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		old := storeAdd(t, store, db.Task{Date: "20240110", Title: "Давно"})
+		yesterday := storeAdd(t, store, db.Task{Date: "20240125", Title: "Вчера", Repeat: "d 7"})
+		today := storeAdd(t, store, db.Task{Date: "20240126", Title: "Сегодня"})
+		week := storeAdd(t, store, db.Task{Date: "20240128", Title: "В воскресенье"})
+		later := storeAdd(t, store, db.Task{Date: "20240205", Title: "Потом"})
+		deleted := storeAdd(t, store, db.Task{Date: "20240120", Title: "Удалена"})
+		assert.NoError(t, store.DeleteTask(deleted, clk.Now()))
+
+		tasks := func(from, to string) []string {
+			page, err := store.Tasks(db.TaskFilter{From: from, To: to}, db.Page{})
+			assert.NoError(t, err)
+			return taskIDs(page.Tasks)
+		}
+		assert.Equal(t, []string{old, yesterday, today, week, later}, tasks("", ""))
+		assert.Equal(t, []string{old, yesterday}, tasks("", "20240125"))
+		assert.Equal(t, []string{today, week}, tasks("20240126", "20240128"))
+		assert.Equal(t, []string{today}, tasks("20240126", "20240126"))
+		assert.Equal(t, []string{later}, tasks("20240129", ""))
+		assert.Empty(t, tasks("20240129", "20240204"))
+	})
+}
+
+func TestAgenda(t *testing.T) {
+	if !Search {
+		return
+	}
+
+	type agendaGroup struct {
+		Name       string              `json:"name"`
+		From       string              `json:"from"`
+		To         string              `json:"to"`
+		Tasks      []map[string]string `json:"tasks"`
+		NextCursor string              `json:"next_cursor"`
+		Total      int                 `json:"total"`
+	}
+	type tasksResp struct {
+		Tasks  []map[string]string `json:"tasks"`
+		Groups []agendaGroup       `json:"groups"`
+		Error  string              `json:"error"`
+	}
+	get := func(params url.Values) tasksResp {
+		body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp tasksResp
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		return resp
+	}
+	pin := func(date string) {
+		ret, err := postJSON("api/debug/now?now="+date, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+	}
+
+	// Задачи добавляются в понедельник 12.10.2026, повестка смотрится в среду
+	pin("20261012")
+	var ids []string
+	defer func() {
+		for _, id := range ids {
+			_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+		_, err := postJSON("api/debug/now", nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}()
+	add := func(date string) string {
+		id := addTask(t, task{date: date, title: "Повестка " + date})
+		ids = append(ids, id)
+		return id
+	}
+	monday := add("20261012")
+	tuesday := add("20261013")
+	wednesday := add("20261014")
+	thursday := add("20261015")
+	saturday := add("20261017")
+	sunday := add("20261018")
+	nextWeek := add("20261020")
+	pin("20261014")
+
+	// Из списка берутся только задачи этого теста: в базе могут быть задачи других тестов
+	ours := func(tasks []map[string]string) []string {
+		var res []string
+		for _, task := range tasks {
+			if slices.Contains(ids, task["id"]) {
+				res = append(res, task["id"])
+			}
+		}
+		return res
+	}
+
+	resp := get(url.Values{"agenda": {"true"}, "limit": {"500"}})
+	assert.Empty(t, resp.Error)
+	groups := map[string]agendaGroup{}
+	var names []string
+	for _, group := range resp.Groups {
+		groups[group.Name] = group
+		names = append(names, group.Name)
+	}
+	assert.Equal(t, []string{"overdue", "today", "tomorrow", "week", "later"}, names)
+	assert.Equal(t, []string{monday, tuesday}, ours(groups["overdue"].Tasks))
+	assert.Equal(t, "", groups["overdue"].From)
+	assert.Equal(t, "20261013", groups["overdue"].To)
+	assert.Equal(t, []string{wednesday}, ours(groups["today"].Tasks))
+	assert.Equal(t, []string{thursday}, ours(groups["tomorrow"].Tasks))
+	assert.Equal(t, []string{saturday, sunday}, ours(groups["week"].Tasks))
+	assert.Equal(t, "20261016", groups["week"].From)
+	assert.Equal(t, "20261018", groups["week"].To)
+	assert.Equal(t, []string{nextWeek}, ours(groups["later"].Tasks))
+	assert.Equal(t, "20261019", groups["later"].From)
+
+	// В субботу неделя заканчивается завтрашним днём
+	pin("20261017")
+	resp = get(url.Values{"agenda": {"true"}, "limit": {"500"}})
+	names = nil
+	for _, group := range resp.Groups {
+		names = append(names, group.Name)
+	}
+	assert.Equal(t, []string{"overdue", "today", "tomorrow", "later"}, names)
+	pin("20261014")
+
+	// Просроченные задачи и диапазоны дат
+	assert.Equal(t, []string{monday, tuesday}, ours(get(url.Values{"overdue": {"true"}}).Tasks))
+	assert.Equal(t, []string{wednesday, thursday, saturday, sunday, nextWeek}, ours(get(url.Values{}).Tasks))
+	assert.Equal(t, []string{tuesday, wednesday, thursday},
+		ours(get(url.Values{"from": {"20261013"}, "to": {"20261015"}}).Tasks))
+	assert.Equal(t, []string{wednesday, thursday, saturday}, ours(get(url.Values{"to": {"20261017"}}).Tasks))
+
+	for params, expected := range map[string]string{
+		"from=2026-10-13":               "некорректный формат from (ожидается 20060102)",
+		"to=13.10.2026":                 "некорректный формат to (ожидается 20060102)",
+		"to=20261013":                   "to (20261013) раньше начала списка (20261014)",
+		"overdue=true&from=20261001":    "overdue не сочетается с from и to",
+		"agenda=true&search=повестка":   "параметры from, to, overdue и agenda не сочетаются с search и archived",
+		"from=20261001&archived=true":   "параметры from, to, overdue и agenda не сочетаются с search и archived",
+		"agenda=true&cursor=eyJpIjoxfQ": "cursor не используется вместе с agenda: следующие страницы группы запрашиваются по её from и to",
+	} {
+		values, err := url.ParseQuery(params)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, get(values).Error, params)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func archivedTasks(t *testing.T) map[string]map[string]any {
+	body, err := requestJSON("api/tasks?archived=true", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+
+	res := make(map[string]map[string]any)
+	for _, task := range resp.Tasks {
+		res[task["id"].(string)] = task
+	}
+	return res
+}
+
+func TestArchive(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	deleted := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Удалённая задача",
+		repeat: "d 3",
+	})
+	done := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Выполненная задача",
+	})
+
+	ret, err := postJSON("api/task?id="+deleted, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/done?id="+done, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, deleted)
+	notFoundTask(t, done)
+
+	// Задачи остались в базе с отметкой об архивации
+	for id, status := range map[string]string{deleted: "deleted", done: "done"} {
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, status, task.Status)
+		_, err = time.Parse(time.RFC3339, task.ArchivedAt)
+		assert.NoError(t, err)
+
+		for _, active := range getTasks(t, "") {
+			assert.NotEqual(t, id, active["id"])
+		}
+	}
+
+	archive := archivedTasks(t)
+	if assert.Contains(t, archive, deleted) {
+		assert.Equal(t, "deleted", archive[deleted]["status"])
+	}
+	if assert.Contains(t, archive, done) {
+		assert.Equal(t, "done", archive[done]["status"])
+	}
+
+	// Повторное удаление и выполнение архивной задачи невозможны
+	ret, err = postJSON("api/task?id="+deleted, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	ret, err = postJSON("api/task/done?id="+done, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = postJSON("api/task/restore?id="+deleted, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/restore?id="+deleted, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	body, err := requestJSON("api/task?id="+deleted, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "Удалённая задача", m["title"])
+	assert.Empty(t, m["status"])
+	assert.NotContains(t, archivedTasks(t), deleted)
+
+	for _, id := range []string{deleted, done} {
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinnedClock(t *testing.T) {
+	if !Debug {
+		return
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	ret, err := postJSON("api/debug/now?now=2024-01-26", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = postJSON("api/debug/now?now=20240126", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ret["pinned"])
+	defer func() {
+		ret, err := postJSON("api/debug/now", nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, false, ret["pinned"])
+	}()
+
+	body, err := getBody("api/nextdate?date=20240120&repeat=d+3")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240129", string(body))
+
+	ret, err = postJSON("api/task", map[string]any{
+		"title":  "Задача в закреплённом дне",
+		"repeat": "w 1,3,5",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	var task Task
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "20240126", task.Date)
+
+	for _, want := range []string{"20240129", "20240131", "20240202"} {
+		ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+		assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+		assert.Equal(t, want, task.Date)
+	}
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
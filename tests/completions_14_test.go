@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type completion struct {
+	ID          string `json:"id"`
+	TaskID      string `json:"task_id"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	CompletedAt string `json:"completed_at"`
+}
+
+func getCompletions(t *testing.T, id string) []completion {
+	url := "api/completions"
+	if id != "" {
+		url += "?id=" + id
+	}
+	body, err := requestJSON(url, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var resp struct {
+		Completions []completion `json:"completions"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp.Completions
+}
+
+func TestCompletions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	id := addTask(t, task{
+		date:   today,
+		title:  "Полить цветы",
+		repeat: "d 2",
+	})
+	assert.Empty(t, getCompletions(t, id))
+
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	list := getCompletions(t, id)
+	assert.Len(t, list, 2)
+	if len(list) == 2 {
+		assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), list[0].Date)
+		assert.Equal(t, today, list[1].Date)
+		for _, c := range list {
+			assert.Equal(t, id, c.TaskID)
+			assert.Equal(t, "Полить цветы", c.Title)
+			_, err := time.Parse(time.RFC3339, c.CompletedAt)
+			assert.NoError(t, err)
+		}
+	}
+
+	single := addTask(t, task{
+		date:  today,
+		title: "Разовая задача",
+	})
+	ret, err := postJSON("api/task/done?id="+single, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, single)
+
+	// Задача удалена, но её выполнение осталось в истории
+	list = getCompletions(t, single)
+	assert.Len(t, list, 1)
+	if len(list) == 1 {
+		assert.Equal(t, "Разовая задача", list[0].Title)
+	}
+
+	all := getCompletions(t, "")
+	assert.GreaterOrEqual(t, len(all), 3)
+	if len(all) > 0 {
+		assert.Equal(t, single, all[0].TaskID)
+	}
+
+	ret, err = postJSON("api/completions?id=abc", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
@@ -1,29 +1,74 @@
 package tests
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	_ "modernc.org/sqlite"
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID          int64  `db:"id"`
+	Date        string `db:"date"`
+	Title       string `db:"title"`
+	Comment     string `db:"comment"`
+	Repeat      string `db:"repeat"`
+	RepeatCount int    `db:"repeat_count"`
+	RepeatUntil string `db:"repeat_until"`
+	DoneCount   int    `db:"done_count"`
+	Exdates     string `db:"exdates"`
+	RepeatStart string `db:"repeat_start"`
+	RepeatMode  string `db:"repeat_mode"`
+	StartTime   string `db:"start_time"`
+	Duration    int    `db:"duration"`
+	Status      string `db:"status"`
+	ArchivedAt  string `db:"archived_at"`
+	Priority    int    `db:"priority"`
+	ProjectID   int64  `db:"project_id"`
 }
 
-func count(db *sqlx.DB) (int, error) {
+func count(db *testDB) (int, error) {
 	var count int
 	return count, db.Get(&count, `SELECT count(id) FROM scheduler`)
 }
 
-func openDB(t *testing.T) *sqlx.DB {
+// testDB - подключение к базе сервера. Запросы в тестах пишутся с параметрами "?",
+// для PostgreSQL они переводятся в "$1, $2, ...".
+type testDB struct {
+	*sqlx.DB
+}
+
+func (db *testDB) Get(dest interface{}, query string, args ...interface{}) error {
+	return db.DB.Get(dest, db.Rebind(query), args...)
+}
+
+func (db *testDB) Select(dest interface{}, query string, args ...interface{}) error {
+	return db.DB.Select(dest, db.Rebind(query), args...)
+}
+
+func (db *testDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Rebind(query), args...)
+}
+
+// postgres сообщает, что сервер работает с PostgreSQL
+func (db *testDB) postgres() bool {
+	return db.DriverName() == "postgres"
+}
+
+// openDB подключается к той же базе, что и сервер: к PostgreSQL, если задана
+// переменная TODO_DB_DSN, иначе к файлу SQLite
+func openDB(t *testing.T) *testDB {
+	if dsn := os.Getenv("TODO_DB_DSN"); dsn != "" {
+		db, err := sqlx.Connect("postgres", dsn)
+		assert.NoError(t, err)
+		return &testDB{db}
+	}
+
 	dbfile := DBFile
 	envFile := os.Getenv("TODO_DBFILE")
 	if len(envFile) > 0 {
@@ -31,7 +76,7 @@ func openDB(t *testing.T) *sqlx.DB {
 	}
 	db, err := sqlx.Connect("sqlite", dbfile)
 	assert.NoError(t, err)
-	return db
+	return &testDB{db}
 }
 
 func TestDB(t *testing.T) {
@@ -43,12 +88,11 @@ func TestDB(t *testing.T) {
 
 	today := time.Now().Format(`20060102`)
 
-	res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) 
-	VALUES (?, 'Todo', 'Комментарий', '')`, today)
+	var id int64
+	err = db.Get(&id, `INSERT INTO scheduler (date, title, comment, repeat) 
+	VALUES (?, 'Todo', 'Комментарий', '') RETURNING id`, today)
 	assert.NoError(t, err)
 
-	id, err := res.LastInsertId()
-
 	var task Task
 	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
 	assert.NoError(t, err)
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getWithLang(t *testing.T, apipath, lang string) []byte {
+	req, err := http.NewRequest(http.MethodGet, getURL(apipath), nil)
+	assert.NoError(t, err)
+	if len(lang) > 0 {
+		req.Header.Set("Accept-Language", lang)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return body
+}
+
+func TestRepeatDescription(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	tbl := []struct {
+		repeat string
+		ru     string
+		en     string
+	}{
+		{"d 3", "каждые 3 дня", "every 3 days"},
+		{"w 1,4 2", "каждые 2 недели по понедельникам и четвергам", "every 2 weeks on Mondays and Thursdays"},
+		{"m 1,-1 2,8", "в феврале и августе 1-го и последнего числа", "in February and August on the 1st and last day"},
+		{"wm 5 -1", "каждый месяц: последняя пятница", "every month on the last Friday"},
+		{"m 25 -bd", "каждый месяц 25-го числа, с переносом на предыдущий рабочий день",
+			"every month on the 25th day, moved to the previous working day if needed"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=2TU;COUNT=5", "каждый месяц: второй вторник, всего 5 раз",
+			"every month on the second Tuesday, 5 times"},
+	}
+	for _, v := range tbl {
+		id := addTask(t, task{
+			date:   now.Format(`20060102`),
+			title:  "Описание правила",
+			repeat: v.repeat,
+		})
+
+		for lang, want := range map[string]string{
+			"":                        v.ru,
+			"ru-RU,ru;q=0.9":          v.ru,
+			"en-US,en;q=0.9":          v.en,
+			"de-DE,en;q=0.8,ru;q=0.5": v.en,
+			"ru;q=0.1, en;q=0.9":      v.en,
+			"en;q=0, ru;q=0.2":        v.ru,
+			"en;q=0":                  v.ru,
+		} {
+			var task map[string]any
+			assert.NoError(t, json.Unmarshal(getWithLang(t, "api/task?id="+id, lang), &task))
+			assert.Equal(t, want, task["repeat_text"], "%q, Accept-Language: %q", v.repeat, lang)
+		}
+	}
+
+	id := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Без повторения",
+	})
+	var task map[string]any
+	assert.NoError(t, json.Unmarshal(getWithLang(t, "api/task?id="+id, "en"), &task))
+	_, ok := task["repeat_text"]
+	assert.False(t, ok, "У задачи без правила не должно быть описания")
+
+	var list struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(getWithLang(t, "api/tasks", "en"), &list))
+	found := false
+	for _, task := range list.Tasks {
+		if task["repeat"] == "wm 5 -1" {
+			found = true
+			assert.Equal(t, "every month on the last Friday", task["repeat_text"])
+		}
+	}
+	assert.True(t, found, "Задача с правилом wm 5 -1 не найдена в списке")
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	var versions []int
+	err := db.Select(&versions, `SELECT version FROM schema_migrations ORDER BY version`)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, versions)
+	for i, v := range versions {
+		assert.Equal(t, i+1, v, "Миграции применены не по порядку: %v", versions)
+	}
+
+	tables := `SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	if db.postgres() {
+		tables = `SELECT count(*) FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = ?`
+	}
+	for _, table := range []string{"scheduler", "completions", "journal", "tags", "task_tags", "projects"} {
+		var n int
+		err = db.Get(&n, tables, table)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n, "Нет таблицы %s", table)
+	}
+}
+
+func TestMigrateRepeatStart(t *testing.T) {
+	// База первой версии: у повторяющихся задач нет начала серии
+	dbFile := filepath.Join(t.TempDir(), "scheduler.db")
+	conn, err := sqlx.Connect("sqlite", dbFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = conn.Exec(`CREATE TABLE scheduler (id INTEGER PRIMARY KEY AUTOINCREMENT, date CHAR(8) NOT NULL,
+		title TEXT NOT NULL, comment TEXT, repeat TEXT);
+		INSERT INTO scheduler (date, title, comment, repeat) VALUES
+			('20240126', 'Спринт-ревью', '', 'w 5 2'),
+			('20240127', 'Разовая', '', '')`)
+	assert.NoError(t, err)
+	conn.Close()
+
+	store, err := db.NewDatabase(dbFile, &clock.Pinned{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+
+	task, err := store.GetTask("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240126", task.RepeatStart)
+	task, err = store.GetTask("2")
+	assert.NoError(t, err)
+	assert.Empty(t, task.RepeatStart)
+}
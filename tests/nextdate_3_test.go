@@ -16,6 +16,23 @@ type nextDate struct {
 	want   string
 }
 
+// checkNextDates проверяет ответы /api/nextdate на день now. Пустое want - ожидается ошибка.
+func checkNextDates(t *testing.T, now string, tbl []nextDate) {
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			now, url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
 func TestNextDate(t *testing.T) {
 	tbl := []nextDate{
 		{"20240126", "", ""},
@@ -37,22 +54,7 @@ func TestNextDate(t *testing.T) {
 		{"20231225", "d 12", `20240130`},
 		{"20240228", "d 1", "20240229"},
 	}
-	check := func() {
-		for _, v := range tbl {
-			urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
-				url.QueryEscape(v.date), url.QueryEscape(v.repeat))
-			get, err := getBody(urlPath)
-			assert.NoError(t, err)
-			next := strings.TrimSpace(string(get))
-			_, err = time.Parse("20060102", next)
-			if err != nil && len(v.want) == 0 {
-				continue
-			}
-			assert.Equal(t, v.want, next, `{%q, %q, %q}`,
-				v.date, v.repeat, v.want)
-		}
-	}
-	check()
+	checkNextDates(t, "20240126", tbl)
 	if !FullNextDate {
 		return
 	}
@@ -75,5 +77,57 @@ func TestNextDate(t *testing.T) {
 		{"20230126", "w 4,5", "20240201"},
 		{"20230226", "w 8,4,5", ""},
 	}
-	check()
+	checkNextDates(t, "20240126", tbl)
+}
+
+func TestNextDateMonthWeekday(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "wm", ""},
+		{"20240126", "wm 2", ""},
+		{"20240126", "wm 8 1", ""},
+		{"20240126", "wm 2 6", ""},
+		{"20240126", "wm 2 -3", ""},
+		{"20240126", "wm 2 1 13", ""},
+		{"20240101", "wm 2 2", "20240213"},
+		{"20240101", "wm 5 -1", "20240126"},
+		{"20240126", "wm 5 -1", "20240223"},
+		{"20240126", "wm 1 -2", "20240219"},
+		{"20240126", "wm 4 1,3", "20240201"},
+		{"20240126", "wm 5 -1 3,6", "20240329"},
+		{"20240126", "wm 4 5 2,5", "20240229"},
+		{"20240301", "wm 4 5 2,5", "20240530"},
+	}
+	checkNextDates(t, "20240125", tbl)
+}
+
+func TestNextDateWeekInterval(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "w 1 0", ""},
+		{"20240126", "w 1 53", ""},
+		{"20240126", "w 1 x", ""},
+		{"20240126", "w 1 2 3", ""},
+		{"20240101", "w 1,4 2", "20240129"},
+		{"20240108", "w 1,4 2", "20240205"},
+		{"20240125", "w 4 2", "20240208"},
+		{"20240126", "w 1,4 3", "20240212"},
+		{"20240126", "w 6 1", "20240127"},
+	}
+	checkNextDates(t, "20240126", tbl)
+}
+
+func TestNextDateBusinessDays(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "bd", ""},
+		{"20240126", "bd 0", ""},
+		{"20240126", "bd 401", ""},
+		{"20240126", "d 1 +bd +bd", ""},
+		{"20240126", "+bd", ""},
+		{"20240126", "bd 1", "20240129"},
+		{"20240122", "bd 5", "20240129"},
+		{"20240126", "m 27 +bd", "20240129"},
+		{"20240126", "m 27 -bd", "20240227"},
+		{"20240126", "RRULE:FREQ=MONTHLY;BYMONTHDAY=3 +bd", "20240205"},
+		{"20230128", "y -bd", "20250128"},
+	}
+	checkNextDates(t, "20240126", tbl)
 }
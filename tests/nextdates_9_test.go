@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nextDates struct {
+	date   string
+	repeat string
+	n      string
+	want   []string
+}
+
+func TestNextDates(t *testing.T) {
+	tbl := []nextDates{
+		{"20240126", "", "", nil},
+		{"20240126", "ooops", "", nil},
+		{"20240126", "d 1", "0", nil},
+		{"20240126", "d 1", "101", nil},
+		{"20240126", "d 1", "x", nil},
+		{"20240126", "m 31 2", "", nil},
+		{"20240126", "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", "", nil},
+		{"20240126", "d 7", "3", []string{"20240202", "20240209", "20240216"}},
+		{"20240101", "w 1,4 2", "4", []string{"20240129", "20240201", "20240212", "20240215"}},
+		{"20240126", "m -1", "3", []string{"20240131", "20240229", "20240331"}},
+		{"20240126", "wm 5 -1 1,2", "3", []string{"20240223", "20250131", "20250228"}},
+		{"20240101", "RRULE:FREQ=DAILY;COUNT=28", "5", []string{"20240127", "20240128"}},
+		{"20240101", "RRULE:FREQ=DAILY;UNTIL=20240126", "5", []string{}},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdates?now=20240126&date=%s&repeat=%s&n=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat), url.QueryEscape(v.n))
+		body, err := getBody(urlPath)
+		assert.NoError(t, err)
+
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		if v.want == nil {
+			e, ok := m["error"]
+			assert.False(t, !ok || len(fmt.Sprint(e)) == 0,
+				"Ожидается ошибка для %v", v)
+			continue
+		}
+
+		var resp struct {
+			Dates []string `json:"dates"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, v.want, resp.Dates, `{%q, %q, %q}`, v.date, v.repeat, v.n)
+	}
+
+	body, err := getBody("api/nextdates?date=20240126&repeat=d+1")
+	assert.NoError(t, err)
+	var resp struct {
+		Dates []string `json:"dates"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Len(t, resp.Dates, 10)
+}
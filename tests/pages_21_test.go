@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+	"todo-server/pkg/query"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// storePages читает список целиком страницами по limit задач и проверяет total на каждой
+func storePages(t *testing.T, limit int, list func(page db.Page) (*db.TaskPage, error)) []string {
+	var ids []string
+	page := db.Page{Limit: limit}
+	for range 100 {
+		res, err := list(page)
+		if !assert.NoError(t, err) {
+			return ids
+		}
+		assert.LessOrEqual(t, len(res.Tasks), limit)
+		ids = append(ids, taskIDs(res.Tasks)...)
+		if res.NextCursor == "" {
+			assert.Equal(t, len(ids), res.Total)
+			return ids
+		}
+		assert.Len(t, res.Tasks, limit)
+		page.Cursor = res.NextCursor
+	}
+	t.Fatal("слишком много страниц")
+	return ids
+}
+
+func TestStorePages(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		var ids []string
+		for i, date := range []string{"20240130", "20240126", "20240128", "20240126", "20240127", "20240128", "20240129"} {
+			task := db.Task{Date: date, Title: fmt.Sprintf("Отчёт %d", i)}
+			if i%3 == 0 {
+				task.StartTime = "10:00"
+			}
+			ids = append(ids, storeAdd(t, store, task))
+		}
+		tasks := func(page db.Page) (*db.TaskPage, error) { return store.Tasks(db.TaskFilter{From: "20240126"}, page) }
+
+		full, err := tasks(db.Page{})
+		assert.NoError(t, err)
+		assert.Equal(t, 7, full.Total)
+		assert.Empty(t, full.NextCursor)
+		order := taskIDs(full.Tasks)
+		for _, limit := range []int{1, 2, 3, 7} {
+			assert.Equal(t, order, storePages(t, limit, tasks), limit)
+		}
+
+		// Задачи, добавленные перед курсором, не сдвигают следующую страницу
+		first, err := tasks(db.Page{Limit: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, 7, first.Total)
+		storeAdd(t, store, db.Task{Date: "20240126", Title: "Отчёт раньше"})
+		next, err := tasks(db.Page{Limit: 3, Cursor: first.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, 8, next.Total)
+		assert.Equal(t, order[3:6], taskIDs(next.Tasks))
+
+		// Поиск по релевантности: страницы идут в том же порядке, что и полный список
+		ids = append(ids, storeAdd(t, store, db.Task{Date: "20240127", Title: "Отчёт", Comment: "отчёт"}))
+		q, err := query.Parse("отчёт")
+		assert.NoError(t, err)
+		search := func(page db.Page) (*db.TaskPage, error) { return store.SearchTasks(q, "20240126", page) }
+		found, err := search(db.Page{})
+		assert.NoError(t, err)
+		assert.Equal(t, ids[7], found.Tasks[0].ID)
+		assert.Equal(t, taskIDs(found.Tasks), storePages(t, 2, search))
+		for _, task := range found.Tasks {
+			assert.Contains(t, task.Snippet, "<mark>")
+		}
+
+		byDate := func(page db.Page) (*db.TaskPage, error) {
+			return store.Tasks(db.TaskFilter{From: "20240128", To: "20240128"}, page)
+		}
+		assert.Equal(t, []string{ids[2], ids[5]}, storePages(t, 1, byDate))
+
+		// Архив - начиная с последних
+		for _, id := range ids[:4] {
+			clk.Set(clk.Now().Add(time.Minute))
+			assert.NoError(t, store.DeleteTask(id, clk.Now()))
+		}
+		archived := func(page db.Page) (*db.TaskPage, error) { return store.ArchivedTasks(page) }
+		assert.Equal(t, []string{ids[3], ids[2], ids[1], ids[0]}, storePages(t, 3, archived))
+
+		// Курсор из другого списка или испорченный курсор - ошибка
+		_, err = search(db.Page{Cursor: first.NextCursor})
+		assert.ErrorIs(t, err, db.ErrInvalidCursor)
+		_, err = tasks(db.Page{Cursor: "не курсор"})
+		assert.ErrorIs(t, err, db.ErrInvalidCursor)
+	})
+}
+
+func TestTasksPagination(t *testing.T) {
+	if !Search {
+		return
+	}
+
+	type tasksResp struct {
+		Tasks      []map[string]any `json:"tasks"`
+		NextCursor string           `json:"next_cursor"`
+		Total      int              `json:"total"`
+		Error      string           `json:"error"`
+	}
+	get := func(params url.Values) tasksResp {
+		body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp tasksResp
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		return resp
+	}
+
+	now := time.Now()
+	var ids []string
+	for i := range 5 {
+		ids = append(ids, addTask(t, task{
+			date:  now.AddDate(0, 0, i+1).Format(`20060102`),
+			title: fmt.Sprintf("Пагинация %d", i),
+		}))
+	}
+	defer func() {
+		for _, id := range ids {
+			_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+	}()
+
+	var got []string
+	params := url.Values{"search": {"пагинация"}, "limit": {"2"}}
+	for range 5 {
+		resp := get(params)
+		assert.Empty(t, resp.Error)
+		assert.Equal(t, 5, resp.Total)
+		for _, task := range resp.Tasks {
+			got = append(got, task["id"].(string))
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		params.Set("cursor", resp.NextCursor)
+	}
+	assert.ElementsMatch(t, ids, got)
+
+	for _, params := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"501"}},
+		{"limit": {"много"}},
+	} {
+		assert.Equal(t, "Параметр limit должен быть от 1 до 500", get(params).Error, params.Encode())
+	}
+	assert.Equal(t, "некорректный курсор страницы", get(url.Values{"cursor": {"abc"}}).Error)
+}
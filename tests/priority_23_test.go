@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+	"todo-server/pkg/query"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorePriority(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		low := storeAdd(t, store, db.Task{Date: "20240126", Title: "Полить цветы"})
+		urgent := storeAdd(t, store, db.Task{Date: "20240128", Title: "Заплатить налог", Priority: 3})
+		high := storeAdd(t, store, db.Task{Date: "20240127", Title: "Allo", Priority: 2})
+		normal := storeAdd(t, store, db.Task{Date: "20240127", Title: "Написать отчёт", Priority: 1})
+		alsoHigh := storeAdd(t, store, db.Task{Date: "20240126", Title: "Позвонить", Priority: 2})
+
+		tasks := func(filter db.TaskFilter, sort string) []string {
+			page, err := store.Tasks(filter, db.Page{Sort: sort})
+			assert.NoError(t, err)
+			return taskIDs(page.Tasks)
+		}
+		all := db.TaskFilter{From: "20240126"}
+		assert.Equal(t, []string{low, alsoHigh, high, normal, urgent}, tasks(all, ""))
+		assert.Equal(t, []string{low, alsoHigh, high, normal, urgent}, tasks(all, db.SortDate))
+		assert.Equal(t, []string{urgent, alsoHigh, high, normal, low}, tasks(all, db.SortPriority))
+		// Заголовки сравниваются побайтово: латиница раньше кириллицы
+		assert.Equal(t, []string{high, urgent, normal, alsoHigh, low}, tasks(all, db.SortTitle))
+		assert.Equal(t, []string{alsoHigh, high}, tasks(db.TaskFilter{From: "20240126", Priorities: []int{2}}, ""))
+		assert.Equal(t, []string{urgent, alsoHigh, high}, tasks(db.TaskFilter{From: "20240126", Priorities: []int{2, 3}}, db.SortPriority))
+		assert.Equal(t, []string{low}, tasks(db.TaskFilter{From: "20240126", Priorities: []int{0}}, ""))
+
+		// Постраничный вывод в каждом порядке
+		for _, sort := range []string{db.SortDate, db.SortPriority, db.SortTitle} {
+			list := func(page db.Page) (*db.TaskPage, error) {
+				page.Sort = sort
+				return store.Tasks(all, page)
+			}
+			assert.Equal(t, tasks(all, sort), storePages(t, 2, list), sort)
+		}
+		first, err := store.Tasks(all, db.Page{Limit: 2})
+		assert.NoError(t, err)
+		_, err = store.Tasks(all, db.Page{Limit: 2, Sort: db.SortPriority, Cursor: first.NextCursor})
+		assert.ErrorIs(t, err, db.ErrInvalidCursor)
+		_, err = store.Tasks(all, db.Page{Sort: "date DESC"})
+		assert.ErrorIs(t, err, db.ErrInvalidSort)
+
+		// Поиск: условие priority: и явный порядок вместо релевантности
+		found, err := storeSearch(store, "priority:2,3", "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{alsoHigh, high, urgent}, taskIDs(found))
+		found, err = storeSearch(store, "-priority:0 -priority:1", "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{alsoHigh, high, urgent}, taskIDs(found))
+		_, err = storeSearch(store, "отчёт priority:4", "20240126")
+		assert.EqualError(t, err, "ошибка в запросе на позиции 7 (priority:4): приоритет должен быть от 0 до 3")
+		_, err = storeSearch(store, "priority:высокий", "20240126")
+		assert.EqualError(t, err, `ошибка в запросе на позиции 1 (priority:высокий): приоритет "высокий" должен быть неотрицательным числом`)
+
+		storeAdd(t, store, db.Task{Date: "20240126", Title: "Отчёт отчёт отчёт"})
+		q, err := query.Parse("отчёт")
+		assert.NoError(t, err)
+		page, err := store.SearchTasks(q, "20240126", db.Page{Sort: db.SortPriority})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Tasks, 2) {
+			assert.Equal(t, normal, page.Tasks[0].ID)
+			assert.Contains(t, page.Tasks[0].Snippet, "<mark>")
+		}
+
+		// Приоритет меняется при обновлении и восстанавливается отменой
+		task, err := store.GetTask(low)
+		assert.NoError(t, err)
+		task.Priority = 3
+		clk.Set(clk.Now().Add(time.Minute))
+		assert.NoError(t, store.UpdateTask(task))
+		task, err = store.GetTask(low)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, task.Priority)
+		undone, err := store.Undo(1, clk.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{low}, undone.Tasks)
+		task, err = store.GetTask(low)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, task.Priority)
+	})
+}
+
+func TestPriority(t *testing.T) {
+	if !Search {
+		return
+	}
+
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+	add := func(title string, priority int) string {
+		ret, err := postJSON("api/task", map[string]any{"date": date, "title": title, "priority": priority}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+		return ret["id"].(string)
+	}
+	b := add("Приоритет Б", 1)
+	a := add("Приоритет А", 3)
+	c := add("Приоритет В", 0)
+	ids := []string{a, b, c}
+	defer func() {
+		for _, id := range ids {
+			_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+	}()
+
+	type tasksResp struct {
+		Tasks []map[string]any `json:"tasks"`
+		Error string           `json:"error"`
+	}
+	get := func(params url.Values) tasksResp {
+		body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp tasksResp
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		return resp
+	}
+	ours := func(params url.Values) []string {
+		resp := get(params)
+		assert.Empty(t, resp.Error, params.Encode())
+		var res []string
+		for _, task := range resp.Tasks {
+			if id := task["id"].(string); slices.Contains(ids, id) {
+				res = append(res, id)
+			}
+		}
+		return res
+	}
+
+	getTask := func(id string) map[string]any {
+		task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		return task
+	}
+	task := getTask(a)
+	assert.Equal(t, 3.0, task["priority"])
+	assert.NotContains(t, getTask(c), "priority")
+
+	assert.Equal(t, []string{b, a, c}, ours(url.Values{}))
+	assert.Equal(t, []string{a, b, c}, ours(url.Values{"sort": {"priority"}}))
+	assert.Equal(t, []string{a, b, c}, ours(url.Values{"sort": {"title"}}))
+	assert.Equal(t, []string{a}, ours(url.Values{"priority": {"3"}}))
+	assert.Equal(t, []string{b, a}, ours(url.Values{"priority": {"1,3"}}))
+	assert.Equal(t, []string{a, b}, ours(url.Values{"search": {"приоритет"}, "priority": {"1,3"}, "sort": {"priority"}}))
+	assert.Equal(t, []string{a, b}, ours(url.Values{"search": {"приоритет -priority:0"}, "sort": {"priority"}}))
+	assert.Equal(t, []string{c}, ours(url.Values{"search": {time.Now().AddDate(0, 0, 3).Format(`02.01.2006`)}, "priority": {"0"}}))
+
+	ret, err := postJSON("api/task", map[string]any{"id": c, "date": date, "title": "Приоритет В", "priority": 2}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, 2.0, getTask(c)["priority"])
+
+	for _, priority := range []int{-1, 4} {
+		ret, err = postJSON("api/task", map[string]any{"date": date, "title": "Неверный приоритет", "priority": priority}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, "приоритет должен быть от 0 до 3", ret["error"])
+		ret, err = postJSON("api/task", map[string]any{"id": c, "date": date, "title": "Приоритет В", "priority": priority}, http.MethodPut)
+		assert.NoError(t, err)
+		assert.Equal(t, "приоритет должен быть от 0 до 3", ret["error"])
+	}
+
+	for params, expected := range map[string]string{
+		"sort=date+DESC":           "sort должен быть date, priority или title",
+		"agenda=true&sort=urgency": "sort должен быть date, priority или title",
+		"priority=4":               "приоритет должен быть от 0 до 3",
+		"priority=1,высокий":       `приоритет "высокий" должен быть неотрицательным числом`,
+		"archived=true&sort=title": "параметры sort, priority, tags и project не применяются к архиву",
+	} {
+		values, err := url.ParseQuery(params)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, get(values).Error, params)
+	}
+}
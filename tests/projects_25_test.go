@@ -0,0 +1,362 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreProjects(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		addProject := func(p db.Project) string {
+			id, err := store.AddProject(&p)
+			assert.NoError(t, err)
+			return strconv.FormatInt(id, 10)
+		}
+		work := addProject(db.Project{Name: " Работа ", Color: "#FF8800"})
+		home := addProject(db.Project{Name: "Дом"})
+		first := addProject(db.Project{Name: "Срочное", Position: 1})
+
+		_, err := store.AddProject(&db.Project{Name: "Работа"})
+		assert.ErrorIs(t, err, db.ErrProjectExists)
+		_, err = store.AddProject(&db.Project{Name: " "})
+		assert.EqualError(t, err, "не указано название проекта")
+		_, err = store.AddProject(&db.Project{Name: "Дача", Color: "red"})
+		assert.EqualError(t, err, "цвет проекта должен быть в формате #rrggbb")
+		_, err = store.AddProject(&db.Project{Name: strings.Repeat("я", 101)})
+		assert.EqualError(t, err, "название проекта длиннее 100 символов")
+
+		report := storeAdd(t, store, db.Task{Date: "20240126", Title: "Написать отчёт", ProjectID: work})
+		plan := storeAdd(t, store, db.Task{Date: "20240127", Title: "План на отчёт", ProjectID: work})
+		flowers := storeAdd(t, store, db.Task{Date: "20240128", Title: "Полить цветы", ProjectID: home})
+		loose := storeAdd(t, store, db.Task{Date: "20240129", Title: "Отчёт без проекта"})
+		_, err = store.AddTask(&db.Task{Date: "20240129", Title: "Неверный проект", ProjectID: "999"})
+		assert.ErrorIs(t, err, db.ErrProjectNotFound)
+		_, err = store.AddTask(&db.Task{Date: "20240129", Title: "Неверный проект", ProjectID: "работа"})
+		assert.ErrorIs(t, err, db.ErrInvalidProject)
+
+		task, err := store.GetTask(report)
+		assert.NoError(t, err)
+		assert.Equal(t, work, task.ProjectID)
+		task, err = store.GetTask(loose)
+		assert.NoError(t, err)
+		assert.Empty(t, task.ProjectID)
+
+		// Проекты по порядку position с числом активных задач
+		projects := func(archived bool) ([]string, map[string]int) {
+			list, err := store.Projects(archived)
+			assert.NoError(t, err)
+			var ids []string
+			counts := map[string]int{}
+			for _, p := range list {
+				ids = append(ids, p.ID)
+				counts[p.Name] = p.Tasks
+			}
+			return ids, counts
+		}
+		ids, counts := projects(false)
+		// При равном position проекты идут в порядке создания
+		assert.Equal(t, []string{work, first, home}, ids)
+		assert.Equal(t, map[string]int{"Срочное": 0, "Работа": 2, "Дом": 1}, counts)
+		list, err := store.Projects(false)
+		assert.NoError(t, err)
+		assert.Equal(t, "#ff8800", list[0].Color)
+
+		// Отбор задач по проекту
+		tasks := func(project string) []string {
+			page, err := store.Tasks(db.TaskFilter{From: "20240126", Project: project}, db.Page{})
+			assert.NoError(t, err)
+			return taskIDs(page.Tasks)
+		}
+		assert.Equal(t, []string{report, plan, flowers, loose}, tasks(""))
+		assert.Equal(t, []string{report, plan}, tasks(work))
+		assert.Equal(t, []string{loose}, tasks(db.NoProject))
+		assert.Empty(t, tasks(first))
+
+		found, err := storeSearch(store, "отчёт project:"+work, "20240126")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{report, plan}, taskIDs(found))
+		found, err = storeSearch(store, "отчёт -project:"+work, "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{loose}, taskIDs(found))
+		found, err = storeSearch(store, "project:none", "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{loose}, taskIDs(found))
+		_, err = storeSearch(store, "project:работа", "20240126")
+		assert.EqualError(t, err, "ошибка в запросе на позиции 1 (project:работа): некорректный идентификатор проекта")
+
+		// Изменение проекта
+		assert.ErrorIs(t, store.UpdateProject(&db.Project{ID: home, Name: "Работа"}), db.ErrProjectExists)
+		assert.ErrorIs(t, store.UpdateProject(&db.Project{ID: "999", Name: "Дача"}), db.ErrProjectNotFound)
+		assert.NoError(t, store.UpdateProject(&db.Project{ID: home, Name: "Дом и сад", Color: "#00aa00", Position: 10}))
+		assert.NoError(t, store.UpdateProject(&db.Project{ID: work, Name: "Работа"}))
+		ids, counts = projects(false)
+		assert.Equal(t, []string{work, first, home}, ids)
+		assert.Equal(t, 1, counts["Дом и сад"])
+
+		// Перенос задачи между проектами и его отмена
+		clk.Set(clk.Now().Add(time.Minute))
+		assert.NoError(t, store.MoveTask(plan, home))
+		assert.Equal(t, []string{plan, flowers}, tasks(home))
+		assert.ErrorIs(t, store.MoveTask(plan, "999"), db.ErrProjectNotFound)
+		assert.Error(t, store.MoveTask("999", home))
+		undone, err := store.Undo(1, clk.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{plan}, undone.Tasks)
+		assert.Equal(t, []string{report, plan}, tasks(work))
+		assert.NoError(t, store.MoveTask(loose, first))
+		assert.NoError(t, store.MoveTask(loose, db.NoProject))
+		assert.Equal(t, []string{loose}, tasks(db.NoProject))
+
+		// Задачи проекта из архива видны только при явном указании проекта
+		assert.NoError(t, store.ArchiveProject(home, clk.Now()))
+		assert.ErrorIs(t, store.ArchiveProject(home, clk.Now()), db.ErrProjectNotFound)
+		assert.Equal(t, []string{report, plan, loose}, tasks(""))
+		assert.Equal(t, []string{flowers}, tasks(home))
+		found, err = storeSearch(store, "цветы", "20240126")
+		assert.NoError(t, err)
+		assert.Empty(t, found)
+		found, err = storeSearch(store, "цветы project:"+home, "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{flowers}, taskIDs(found))
+		ids, _ = projects(true)
+		assert.Equal(t, []string{home}, ids)
+		assert.ErrorIs(t, store.MoveTask(report, home), db.ErrProjectArchived)
+		_, err = store.AddTask(&db.Task{Date: "20240129", Title: "В архивный проект", ProjectID: home})
+		assert.ErrorIs(t, err, db.ErrProjectArchived)
+		// Задачу в проекте из архива можно изменить, не перенося её
+		task, err = store.GetTask(flowers)
+		assert.NoError(t, err)
+		task.Title = "Полить розы"
+		assert.NoError(t, store.UpdateTask(task))
+		assert.NoError(t, store.UnarchiveProject(home))
+		assert.EqualError(t, store.UnarchiveProject(home), "проект не найден в архиве")
+		assert.Equal(t, []string{report, plan, flowers, loose}, tasks(""))
+
+		// Удаление с переносом задач в другой проект
+		assert.EqualError(t, store.DeleteProject(home, false, home, clk.Now()), "задачи нельзя перенести в удаляемый проект")
+		assert.ErrorIs(t, store.DeleteProject(home, false, "999", clk.Now()), db.ErrProjectNotFound)
+		assert.NoError(t, store.DeleteProject(home, false, work, clk.Now()))
+		assert.Equal(t, []string{report, plan, flowers}, tasks(work))
+		assert.ErrorIs(t, store.DeleteProject(home, false, db.NoProject, clk.Now()), db.ErrProjectNotFound)
+
+		// Каскадное удаление: активные задачи уходят в архив, отмена возвращает проект вместе с ними
+		clk.Set(clk.Now().Add(time.Minute))
+		assert.NoError(t, store.DeleteProject(work, true, "", clk.Now()))
+		assert.Equal(t, []string{loose}, tasks(""))
+		ids, _ = projects(false)
+		assert.Equal(t, []string{first}, ids)
+		archived, err := store.ArchivedTasks(db.Page{})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{report, plan, flowers}, taskIDs(archived.Tasks))
+		undone, err = store.Undo(10, clk.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{work}, undone.Projects)
+		assert.Equal(t, []string{report, plan, flowers}, undone.Tasks)
+		ids, _ = projects(false)
+		assert.ElementsMatch(t, []string{work, first}, ids)
+		assert.Equal(t, []string{report, plan, flowers}, tasks(work))
+		archived, err = store.ArchivedTasks(db.Page{})
+		assert.NoError(t, err)
+		assert.Empty(t, archived.Tasks)
+
+		// Если название удалённого проекта заняли, отмена не выполняется, а задачи из архива
+		// восстанавливаются без проекта
+		assert.NoError(t, store.DeleteProject(work, true, "", clk.Now()))
+		addProject(db.Project{Name: "Работа"})
+		_, err = store.Undo(10, clk.Now())
+		assert.ErrorIs(t, err, db.ErrProjectExists)
+		assert.NoError(t, store.RestoreTask(report))
+		task, err = store.GetTask(report)
+		assert.NoError(t, err)
+		assert.Empty(t, task.ProjectID)
+		assert.Equal(t, []string{report, loose}, tasks(db.NoProject))
+	})
+}
+
+func TestProjects(t *testing.T) {
+	if !Search {
+		return
+	}
+
+	type project struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Color    string `json:"color"`
+		Position int    `json:"position"`
+		Tasks    int    `json:"tasks"`
+	}
+	projects := func(archived bool) map[string]project {
+		path := "api/projects"
+		if archived {
+			path += "?archived=true"
+		}
+		body, err := requestJSON(path, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp struct {
+			Projects []project `json:"projects"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		res := map[string]project{}
+		for _, p := range resp.Projects {
+			if strings.HasPrefix(p.Name, "Тест-") {
+				res[p.Name] = p
+			}
+		}
+		return res
+	}
+	addProject := func(name, color string) string {
+		ret, err := postJSON("api/project", map[string]any{"name": name, "color": color}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+		return ret["id"].(string)
+	}
+	work := addProject("Тест-работа", "#336699")
+	home := addProject("Тест-дом", "")
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	add := func(title, project string) string {
+		ret, err := postJSON("api/task", map[string]any{"date": date, "title": title, "project_id": project}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+		return ret["id"].(string)
+	}
+	report := add("Проекты отчёт", work)
+	flowers := add("Проекты цветы", home)
+	loose := add("Проекты без проекта", "")
+	ids := []string{report, flowers, loose}
+	defer func() {
+		for _, id := range ids {
+			_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+		for _, archived := range []bool{false, true} {
+			for _, p := range projects(archived) {
+				_, err := postJSON("api/project?id="+p.ID+"&move_to=none", nil, http.MethodDelete)
+				assert.NoError(t, err)
+			}
+		}
+	}()
+
+	list := projects(false)
+	assert.Equal(t, "#336699", list["Тест-работа"].Color)
+	assert.Equal(t, 1, list["Тест-работа"].Tasks)
+	assert.Less(t, list["Тест-работа"].Position, list["Тест-дом"].Position)
+	task, err := postJSON("api/task?id="+report, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, work, task["project_id"])
+
+	type tasksResp struct {
+		Tasks []map[string]any `json:"tasks"`
+		Error string           `json:"error"`
+	}
+	get := func(params url.Values) tasksResp {
+		body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp tasksResp
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		return resp
+	}
+	ours := func(params url.Values) []string {
+		resp := get(params)
+		assert.Empty(t, resp.Error, params.Encode())
+		var res []string
+		for _, task := range resp.Tasks {
+			if id := task["id"].(string); slices.Contains(ids, id) {
+				res = append(res, id)
+			}
+		}
+		return res
+	}
+	assert.Equal(t, []string{report, flowers, loose}, ours(url.Values{}))
+	assert.Equal(t, []string{report}, ours(url.Values{"project": {work}}))
+	assert.Equal(t, []string{loose}, ours(url.Values{"project": {"none"}}))
+	assert.Equal(t, []string{flowers}, ours(url.Values{"search": {"проекты"}, "project": {home}}))
+	assert.Equal(t, []string{flowers, loose}, ours(url.Values{"search": {"проекты -project:" + work}}))
+
+	// Изменение проекта и перенос задачи
+	ret, err := postJSON("api/project", map[string]any{"id": home, "name": "Тест-работа"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, "проект с таким названием уже существует", ret["error"])
+	ret, err = postJSON("api/project", map[string]any{"id": home, "name": "Тест-дача", "color": "#00AA00"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, "#00aa00", projects(false)["Тест-дача"].Color)
+
+	ret, err = postJSON("api/task/move?id="+loose+"&project="+work, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{report, loose}, ours(url.Values{"project": {work}}))
+	ret, err = postJSON("api/task/move?id="+loose+"&project=999999", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "проект не найден", ret["error"])
+
+	// Архив проектов
+	ret, err = postJSON("api/project/archive?id="+home, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{report, loose}, ours(url.Values{}))
+	assert.Equal(t, []string{flowers}, ours(url.Values{"project": {home}}))
+	assert.Contains(t, projects(true), "Тест-дача")
+	ret, err = postJSON("api/task/move?id="+report+"&project="+home, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "проект в архиве", ret["error"])
+	ret, err = postJSON("api/project/archive?id="+home, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{report, flowers, loose}, ours(url.Values{}))
+
+	// Удаление с переносом задач и каскадное удаление
+	ret, err = postJSON("api/project?id="+home, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, "Укажите cascade=true или move_to", ret["error"])
+	ret, err = postJSON("api/project?id="+home+"&cascade=true&move_to="+work, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, "Укажите cascade=true или move_to", ret["error"])
+	ret, err = postJSON("api/project?id="+home+"&move_to="+work, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{report, flowers, loose}, ours(url.Values{"project": {work}}))
+	ret, err = postJSON("api/project?id="+work+"&cascade=true", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, ours(url.Values{}))
+	assert.Empty(t, projects(false))
+
+	// Отмена возвращает проект вместе с его задачами
+	ret, err = postJSON("api/undo", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{work}, ret["projects"])
+	assert.Equal(t, []string{report, flowers, loose}, ours(url.Values{"project": {work}}))
+	assert.Len(t, projects(false), 1)
+	ret, err = postJSON("api/project?id="+work+"&cascade=true", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, projects(false))
+
+	ret, err = postJSON("api/project", map[string]any{"name": "Тест-цвет", "color": "red"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "цвет проекта должен быть в формате #rrggbb", ret["error"])
+	ret, err = postJSON("api/task", map[string]any{"date": date, "title": "Неверный проект", "project_id": "999999"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "проект не найден", ret["error"])
+	for params, expected := range map[string]string{
+		"project=работа":          "некорректный идентификатор проекта",
+		"project=0":               "некорректный идентификатор проекта",
+		"archived=true&project=1": "параметры sort, priority, tags и project не применяются к архиву",
+		"search=project:работа":   "ошибка в запросе на позиции 1 (project:работа): некорректный идентификатор проекта",
+	} {
+		values, err := url.ParseQuery(params)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, get(values).Error, params)
+	}
+}
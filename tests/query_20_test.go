@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreQuery(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, _ *clock.Pinned) {
+		past := storeAdd(t, store, db.Task{Date: "20261020", Title: "Отчёт за октябрь"})
+		weekly := storeAdd(t, store, db.Task{Date: "20261102", Title: "Отчёт по проекту", Repeat: "d 7"})
+		once := storeAdd(t, store, db.Task{Date: "20261115", Title: "Сдать отчёт", Comment: "бухгалтерия"})
+		late := storeAdd(t, store, db.Task{Date: "20261201", Title: "Годовой отчёт"})
+
+		search := func(search string) []string {
+			tasks, err := storeSearch(store, search, "20261101")
+			assert.NoError(t, err, search)
+			return taskIDs(tasks)
+		}
+
+		// Без from: задачи ищутся начиная с сегодняшнего дня
+		assert.ElementsMatch(t, []string{weekly, once, late}, search("отчёт"))
+		assert.ElementsMatch(t, []string{past, weekly, once, late}, search("отчёт from:01.10.2026"))
+		assert.Equal(t, []string{weekly, once}, search("from:01.11.2026 to:30.11.2026"))
+		assert.Equal(t, []string{once}, search("date:15.11.2026"))
+		assert.Equal(t, []string{once}, search("date:20261115"))
+		assert.Equal(t, []string{weekly}, search("repeat:yes"))
+		assert.Equal(t, []string{once, late}, search("repeat:no"))
+		assert.Equal(t, []string{once, late}, search("-repeat:yes"))
+		assert.ElementsMatch(t, []string{weekly, late}, search("отчёт -бухгалтерия"))
+		assert.ElementsMatch(t, []string{weekly, late}, search(`отчёт -"сдать отчёт"`))
+		assert.Equal(t, []string{weekly}, search("-сдать -годовой"))
+		assert.Equal(t, []string{once}, search(`"сдать отчёт" to:30.11.2026 repeat:no`))
+		// Слово с двоеточием, кроме имён условий, остаётся текстом
+		todo := storeAdd(t, store, db.Task{Date: "20261105", Title: "TODO: починить кран", Comment: "см. http://example.com/kran"})
+		assert.Equal(t, []string{todo}, search("TODO: починить"))
+		assert.Equal(t, []string{todo}, search("http://example.com/kran"))
+		assert.Empty(t, search("Re: отчёт"))
+		assert.Empty(t, search("from:01.12.2026 to:30.11.2026"))
+
+		_, err := storeSearch(store, "отчёт tag:work,", "20261101")
+		assert.EqualError(t, err, "ошибка в запросе на позиции 7 (tag:work,): не указано имя тега")
+	})
+}
+
+func TestQueryErrors(t *testing.T) {
+	if !Search {
+		return
+	}
+
+	for search, expected := range map[string]string{
+		`from:31.02.2026`:      `ошибка в запросе на позиции 1 (from:31.02.2026): неверная дата "31.02.2026", ожидается ДД.ММ.ГГГГ`,
+		`купить "молоко`:       `ошибка в запросе на позиции 8 ("молоко): не закрыта кавычка`,
+		`"купить"молоко`:       `ошибка в запросе на позиции 1 ("купить"молоко): после закрывающей кавычки ожидается пробел`,
+		`repeat:maybe`:         `ошибка в запросе на позиции 1 (repeat:maybe): ожидается repeat:yes или repeat:no`,
+		`отчёт -to:01.01.2027`: `ошибка в запросе на позиции 7 (-to:01.01.2027): условие to: нельзя исключить`,
+		`отчёт   date:`:        `ошибка в запросе на позиции 9 (date:): не указано значение условия date:`,
+		`tag:дом,,сад`:         `ошибка в запросе на позиции 1 (tag:дом,,сад): не указано имя тега`,
+	} {
+		body, err := requestJSON("api/tasks?search="+url.QueryEscape(search), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Equal(t, expected, m["error"], search)
+	}
+
+	// Время в тексте не считается условием
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Созвон в 10:30"})
+	tasks := getTasks(t, url.QueryEscape("10:30 repeat:no"))
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, id, tasks[0]["id"])
+	}
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+}
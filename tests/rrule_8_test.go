@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateRRule(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "RRULE:", ""},
+		{"20240126", "RRULE:INTERVAL=2", ""},
+		{"20240126", "RRULE:FREQ=HOURLY", ""},
+		{"20240126", "RRULE:FREQ=DAILY;COUNT=3;UNTIL=20240301", ""},
+		{"20240126", "RRULE:FREQ=WEEKLY;BYDAY=2MO", ""},
+		{"20240126", "RRULE:FREQ=DAILY;BYSETPOS=1", ""},
+		{"20240101", "RRULE:FREQ=DAILY;COUNT=10", ""},
+		{"20240101", "RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", ""},
+		{"20240113", "RRULE:FREQ=DAILY;INTERVAL=7", "20240127"},
+		{"20240101", "RRULE:FREQ=DAILY;COUNT=30", "20240127"},
+		{"20240101", "RRULE:FREQ=DAILY;UNTIL=20240301", "20240127"},
+		{"20240101", "rrule:freq=weekly;interval=3;byday=mo,fr", "20240212"},
+		{"20240126", "RRULE:FREQ=WEEKLY", "20240202"},
+		{"20240126", "RRULE:FREQ=MONTHLY;BYDAY=2TU", "20240213"},
+		{"20240126", "RRULE:FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240131", "RRULE:FREQ=MONTHLY", "20240331"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYMONTHDAY=1,-1;BYMONTH=2,8", "20240201"},
+		{"20240229", "RRULE:FREQ=YEARLY", "20280229"},
+		{"20240101", "RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "20241128"},
+	}
+	checkNextDates(t, "20240126", tbl)
+}
+
+func TestDoneRRule(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Ежедневная задача на два дня",
+		repeat: fmt.Sprintf("RRULE:FREQ=DAILY;UNTIL=%s", now.AddDate(0, 0, 1).Format(`20060102`)),
+	})
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
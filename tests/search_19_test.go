@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	if !Search {
+		return
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	ids := []string{
+		addTask(t, task{date: date, title: "Купить ВАТРУШКИ", comment: "в пекарне у дома"}),
+		addTask(t, task{date: date, title: "Ватрушки, ватрушки", comment: "испечь ватрушки к чаю"}),
+		addTask(t, task{date: date, title: "Позвонить в пекарню"}),
+	}
+
+	search := func(query string) []map[string]string {
+		return getTasks(t, url.QueryEscape(query))
+	}
+	idsOf := func(tasks []map[string]string) []string {
+		res := []string{}
+		for _, task := range tasks {
+			res = append(res, task["id"])
+		}
+		return res
+	}
+
+	// Чем больше совпадений, тем выше задача в списке
+	tasks := search("ватрушки")
+	assert.Equal(t, []string{ids[1], ids[0]}, idsOf(tasks))
+	if len(tasks) == 2 {
+		assert.Equal(t, "<mark>Ватрушки</mark>, <mark>ватрушки</mark>", tasks[0]["snippet"])
+		assert.Equal(t, "Купить <mark>ВАТРУШКИ</mark>", tasks[1]["snippet"])
+	}
+
+	assert.Equal(t, []string{ids[2], ids[0]}, idsOf(search("пекарн*")))
+	assert.Equal(t, []string{ids[1]}, idsOf(search(`"испечь ватрушки"`)))
+	assert.Empty(t, search(`"ватрушки испечь"`))
+	assert.Equal(t, []string{ids[0]}, idsOf(search("ватрушки пекарне")))
+
+	for _, id := range ids {
+		ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	assert.Empty(t, search("ватрушки"))
+
+	for _, id := range ids {
+		_, err := db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+}
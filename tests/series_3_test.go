@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	"todo-server/pkg/recurrence"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesOrigin(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(`20060102`, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tbl := []struct {
+		repeat string
+		start  string
+		date   string // текущая дата задачи, пустая - совпадает с началом серии
+		origin string
+		next   string
+	}{
+		{"d 7", "20240101", "", "20240101", "20240108"},
+		{"d 7", "20240101", "20240108", "20240101", "20240115"},
+		// дату перенесли на день вперёд - серия отсчитывается от новой даты
+		{"d 7", "20240101", "20240109", "20240109", "20240116"},
+		{"w 1 2", "20240101", "20240115", "20240101", "20240129"},
+		// понедельник вне двухнедельной сетки
+		{"w 1 2", "20240101", "20240108", "20240108", "20240122"},
+		{"m 15", "20240115", "20240315", "20240115", "20240415"},
+		{"m 15", "20240115", "20240320", "20240320", "20240415"},
+		// дата задачи раньше начала серии
+		{"d 7", "20240110", "20240103", "20240103", "20240110"},
+	}
+	for _, v := range tbl {
+		rule, err := recurrence.Parse(v.repeat)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		series := recurrence.Series{Rule: rule, Start: day(v.start)}
+		if v.date != "" {
+			series.Date = day(v.date)
+		}
+
+		assert.Equal(t, v.origin, series.Origin().Format(`20060102`), "%q start %s date %s", v.repeat, v.start, v.date)
+		next, err := series.Next(day("20240101"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, v.next, next.Format(`20060102`), "%q start %s date %s", v.repeat, v.start, v.date)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+	"todo-server/pkg/query"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPostgresPort - порт встроенного PostgreSQL, чтобы не занять порт установленного сервера
+const testPostgresPort = 54329
+
+// testPostgres - PostgreSQL для контрактных тестов: внешняя база из TODO_TEST_DSN (например,
+// сервис PostgreSQL в CI) или встроенный сервер, который запускается при первом обращении
+// и останавливается в TestMain
+var testPostgres struct {
+	once sync.Once
+	dsn  string
+	err  error
+	stop func()
+}
+
+// postgresDSN возвращает строку подключения к тестовому PostgreSQL
+func postgresDSN() (string, error) {
+	testPostgres.once.Do(func() {
+		// TODO_TEST_DB_DSN - прежнее название переменной
+		for _, env := range []string{"TODO_TEST_DSN", "TODO_TEST_DB_DSN"} {
+			if dsn := os.Getenv(env); dsn != "" {
+				testPostgres.dsn = dsn
+				return
+			}
+		}
+
+		dir, err := os.MkdirTemp("", "todo-postgres")
+		if err != nil {
+			testPostgres.err = err
+			return
+		}
+		// Регистр слов в to_tsvector приводится по локали базы: с локалью C кириллица
+		// не приводилась бы к нижнему регистру, в отличие от токенизатора FTS5
+		pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+			Port(testPostgresPort).
+			RuntimePath(dir).
+			Encoding("UTF8").
+			Locale("C.UTF-8").
+			Logger(io.Discard))
+		if err = pg.Start(); err != nil {
+			os.RemoveAll(dir)
+			testPostgres.err = err
+			return
+		}
+		testPostgres.stop = func() {
+			pg.Stop()
+			os.RemoveAll(dir)
+		}
+		testPostgres.dsn = fmt.Sprintf("host=localhost port=%d user=postgres password=postgres dbname=postgres sslmode=disable",
+			testPostgresPort)
+	})
+	return testPostgres.dsn, testPostgres.err
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if testPostgres.stop != nil {
+		testPostgres.stop()
+	}
+	os.Exit(code)
+}
+
+// taskStores возвращает реализации db.TaskStore, которые должны пройти контрактные тесты.
+// Каждая функция создаёт пустое хранилище. PostgreSQL - база из TODO_TEST_DSN или встроенный
+// сервер; таблицы базы очищаются. Если сервер не удалось запустить, тест завершается ошибкой:
+// пропустить PostgreSQL можно только явно, с TODO_TEST_SKIP_POSTGRES=1.
+func taskStores() map[string]func(t *testing.T, clk clock.Clock) db.TaskStore {
+	return map[string]func(t *testing.T, clk clock.Clock) db.TaskStore{
+		"memory": func(t *testing.T, clk clock.Clock) db.TaskStore {
+			return db.NewMemory(clk)
+		},
+		"sqlite": func(t *testing.T, clk clock.Clock) db.TaskStore {
+			store, err := db.NewDatabase(filepath.Join(t.TempDir(), "scheduler.db"), clk)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"postgres": func(t *testing.T, clk clock.Clock) db.TaskStore {
+			if os.Getenv("TODO_TEST_SKIP_POSTGRES") == "1" {
+				t.Skip("проверка PostgreSQL отключена (TODO_TEST_SKIP_POSTGRES=1)")
+			}
+			dsn, err := postgresDSN()
+			if err != nil {
+				t.Fatalf("PostgreSQL не запущен: %v (укажите базу в TODO_TEST_DSN "+
+					"или отключите проверку с TODO_TEST_SKIP_POSTGRES=1)", err)
+			}
+			store, err := db.NewPostgres(dsn, clk)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			t.Cleanup(func() { store.Close() })
+
+			conn, err := sqlx.Connect("postgres", dsn)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer conn.Close()
+			_, err = conn.Exec(`TRUNCATE scheduler, completions, journal, tags, task_tags, projects RESTART IDENTITY`)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			return store
+		},
+	}
+}
+
+// testStores запускает проверку для каждой реализации хранилища
+func testStores(t *testing.T, test func(t *testing.T, store db.TaskStore, clk *clock.Pinned)) {
+	for name, newStore := range taskStores() {
+		t.Run(name, func(t *testing.T) {
+			clk := &clock.Pinned{}
+			clk.Set(time.Date(2024, 1, 26, 9, 30, 0, 0, time.UTC))
+			test(t, newStore(t, clk), clk)
+		})
+	}
+}
+
+func storeAdd(t *testing.T, store db.TaskStore, task db.Task) string {
+	id, err := store.AddTask(&task)
+	assert.NoError(t, err)
+	return strconv.FormatInt(id, 10)
+}
+
+func storeSearch(store db.TaskStore, search, today string) ([]*db.Task, error) {
+	q, err := query.Parse(search)
+	if err != nil {
+		return nil, err
+	}
+	page, err := store.SearchTasks(q, today, db.Page{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Tasks, nil
+}
+
+func taskIDs(tasks []*db.Task) []string {
+	ids := []string{}
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestStoreTasks(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		id := storeAdd(t, store, db.Task{
+			Date:        "20240126",
+			Title:       "Планёрка",
+			Comment:     "Обсудить релиз",
+			Repeat:      "d 7",
+			RepeatCount: 5,
+			Exdates:     []string{"20240202"},
+			RepeatStart: "20240126",
+			StartTime:   "10:00",
+			Duration:    15,
+		})
+
+		task, err := store.GetTask(id)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, &db.Task{
+			ID:          id,
+			Date:        "20240126",
+			Title:       "Планёрка",
+			Comment:     "Обсудить релиз",
+			Repeat:      "d 7",
+			RepeatCount: 5,
+			Exdates:     []string{"20240202"},
+			RepeatStart: "20240126",
+			StartTime:   "10:00",
+			Duration:    15,
+		}, task)
+
+		task.Title = "Ретро"
+		task.Exdates = nil
+		assert.NoError(t, store.UpdateTask(task))
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Ретро", task.Title)
+		assert.Nil(t, task.Exdates)
+
+		assert.NoError(t, store.UpdateExdates(id, []string{"20240209", "20240216"}))
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"20240209", "20240216"}, task.Exdates)
+
+		assert.NoError(t, store.UpdateDate(id, "20240202"))
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "20240202", task.Date)
+
+		// Пропуск заменяет исключения и переносит задачу одной операцией журнала
+		assert.NoError(t, store.SkipTask(id, []string{"20240202", "20240209"}, "20240223", "20240126", clk.Now()))
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "20240223", task.Date)
+		assert.Equal(t, []string{"20240202", "20240209"}, task.Exdates)
+		undone, err := store.Undo(1, clk.Now().Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{id}, undone.Tasks)
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "20240202", task.Date)
+		assert.Equal(t, []string{"20240209", "20240216"}, task.Exdates)
+
+		_, err = store.GetTask("x")
+		assert.EqualError(t, err, "некорректный идентификатор задачи")
+		for _, err := range []error{
+			func() error { _, err := store.GetTask("100500"); return err }(),
+			store.UpdateTask(&db.Task{ID: "100500", Date: "20240126", Title: "Нет"}),
+			store.UpdateDate("100500", "20240126"),
+			store.UpdateExdates("100500", nil),
+			store.SkipTask("100500", nil, "", "", time.Now()),
+			store.CompleteTask("100500", "", "", time.Now()),
+			store.DeleteTask("100500", time.Now()),
+		} {
+			assert.EqualError(t, err, "задача не найдена")
+		}
+	})
+}
+
+func TestStoreOrder(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, _ *clock.Pinned) {
+		late := storeAdd(t, store, db.Task{Date: "20240127", Title: "Вечер", StartTime: "18:00"})
+		allDay := storeAdd(t, store, db.Task{Date: "20240127", Title: "Весь день"})
+		morning := storeAdd(t, store, db.Task{Date: "20240127", Title: "Утро", StartTime: "09:00"})
+		next := storeAdd(t, store, db.Task{Date: "20240201", Title: "Потом"})
+		first := storeAdd(t, store, db.Task{Date: "20240126", Title: "Сегодня"})
+		storeAdd(t, store, db.Task{Date: "20240125", Title: "Вчера"})
+		second := storeAdd(t, store, db.Task{Date: "20240126", Title: "Сегодня тоже"})
+
+		tasks, err := store.Tasks(db.TaskFilter{From: "20240126"}, db.Page{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{first, second, allDay, morning, late, next}, taskIDs(tasks.Tasks))
+
+		tasks, err = store.Tasks(db.TaskFilter{From: "20240126"}, db.Page{Limit: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{first, second, allDay}, taskIDs(tasks.Tasks))
+
+		tasks, err = store.Tasks(db.TaskFilter{From: "20240301"}, db.Page{})
+		assert.NoError(t, err)
+		assert.NotNil(t, tasks.Tasks)
+		assert.Empty(t, tasks.Tasks)
+
+		tasks, err = store.Tasks(db.TaskFilter{From: "20240127", To: "20240127"}, db.Page{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{allDay, morning, late}, taskIDs(tasks.Tasks))
+	})
+}
+
+func TestStoreSearch(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		milk := storeAdd(t, store, db.Task{Date: "20240127", Title: "Buy milk"})
+		bread := storeAdd(t, store, db.Task{Date: "20240126", Title: "Магазин", Comment: "buy bread"})
+		storeAdd(t, store, db.Task{Date: "20240125", Title: "Buy eggs"})
+		call := storeAdd(t, store, db.Task{Date: "20240128", Title: "Позвонить маме",
+			Comment: "Спросить про молоко и молочные продукты"})
+		dairy := storeAdd(t, store, db.Task{Date: "20240129", Title: "Молоко, молоко и ещё раз молоко"})
+		report := storeAdd(t, store, db.Task{Date: "20240130", Title: "R&D <отчёт>"})
+
+		search := func(search string) []*db.Task {
+			tasks, err := storeSearch(store, search, "20240126")
+			assert.NoError(t, err)
+			assert.NotNil(t, tasks)
+			return tasks
+		}
+		snippets := func(tasks []*db.Task) []string {
+			res := []string{}
+			for _, task := range tasks {
+				res = append(res, task.Snippet)
+			}
+			return res
+		}
+
+		// Короткая задача с тем же словом релевантнее, регистр не учитывается
+		tasks := search("BUY")
+		assert.Equal(t, []string{milk, bread}, taskIDs(tasks))
+		assert.Equal(t, []string{"<mark>Buy</mark> milk", "<mark>buy</mark> bread"}, snippets(tasks))
+		assert.Equal(t, []string{bread}, taskIDs(search("МАГАЗИН")))
+		assert.Empty(t, search("агаз"))
+
+		// Все слова запроса должны встретиться в задаче, фраза - подряд
+		assert.Equal(t, []string{bread}, taskIDs(search("buy bread")))
+		assert.Equal(t, []string{milk}, taskIDs(search(`"buy milk"`)))
+		assert.Empty(t, search(`"milk buy"`))
+
+		// Поиск по префиксу: больше совпадений - выше в списке
+		tasks = search("мол*")
+		assert.Equal(t, []string{dairy, call}, taskIDs(tasks))
+		assert.Equal(t, "Спросить про <mark>молоко</mark> и <mark>молочные</mark> продукты", tasks[1].Snippet)
+		assert.Equal(t, []string{call}, taskIDs(search(`"про мол"*`)))
+
+		tasks = search("отчёт")
+		assert.Equal(t, []string{report}, taskIDs(tasks))
+		assert.Equal(t, []string{"R&amp;D &lt;<mark>отчёт</mark>&gt;"}, snippets(tasks))
+
+		// Регистр кириллицы не учитывается ни в запросе, ни в тексте задачи: в PostgreSQL
+		// это зависит от локали базы, в SQLite - от токенизатора unicode61
+		audit := storeAdd(t, store, db.Task{Date: "20240131", Title: "Созвон с БУХГАЛТЕРИЕЙ",
+			Comment: "Подготовить Акт сверки"})
+		for _, q := range []string{"бухгалтерией", "БухГалтерИей", "пОДГОТОВИТЬ акт", `"АКТ Сверки"`, "СВЕР*"} {
+			assert.Equal(t, []string{audit}, taskIDs(search(q)), q)
+		}
+		assert.Equal(t, []string{"Созвон с <mark>БУХГАЛТЕРИЕЙ</mark>"}, snippets(search("бухгалтериЕЙ")))
+
+		_, err := storeSearch(store, "!!!", "20240126")
+		assert.EqualError(t, err, "ошибка в запросе на позиции 1 (!!!): в запросе нет слов для поиска")
+		assert.Empty(t, search("кефир"))
+
+		// Индекс обновляется при изменении, удалении и восстановлении задачи
+		task, err := store.GetTask(milk)
+		assert.NoError(t, err)
+		task.Title = "Купить кефир"
+		assert.NoError(t, store.UpdateTask(task))
+		assert.Equal(t, []string{milk}, taskIDs(search("кефир")))
+		assert.Equal(t, []string{bread}, taskIDs(search("buy")))
+
+		assert.NoError(t, store.DeleteTask(milk, clk.Now()))
+		assert.Empty(t, search("кефир"))
+		_, err = store.Undo(2, clk.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{milk, bread}, taskIDs(search("buy")))
+
+		// Длинный комментарий сокращается до фрагмента вокруг совпадения
+		words := make([]string, 30)
+		for i := range words {
+			words[i] = fmt.Sprintf("слово%d", i)
+		}
+		words[20] = "цель"
+		words[2] = "начало"
+		storeAdd(t, store, db.Task{Date: "20240126", Title: "Длинная", Comment: strings.Join(words, " ")})
+		tasks = search("цель")
+		if assert.Len(t, tasks, 1) {
+			assert.Equal(t, "…слово14 слово15 слово16 слово17 слово18 слово19 <mark>цель</mark> слово21 слово22 "+
+				"слово23 слово24 слово25 слово26 слово27 слово28 слово29", tasks[0].Snippet)
+		}
+		tasks = search("начало")
+		if assert.Len(t, tasks, 1) {
+			assert.Equal(t, "слово0 слово1 <mark>начало</mark> слово3 слово4 слово5 слово6 слово7 слово8 "+
+				"слово9 слово10 слово11 слово12 слово13 слово14 слово15…", tasks[0].Snippet)
+		}
+	})
+}
+
+func TestStoreComplete(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		repeating := storeAdd(t, store, db.Task{Date: "20240126", Title: "Полить цветы", Repeat: "d 3"})
+		once := storeAdd(t, store, db.Task{Date: "20240126", Title: "Сдать отчёт"})
+
+		assert.NoError(t, store.CompleteTask(repeating, "20240129", "20240129", clk.Now()))
+		task, err := store.GetTask(repeating)
+		assert.NoError(t, err)
+		assert.Equal(t, "20240129", task.Date)
+		assert.Equal(t, "20240129", task.RepeatStart)
+		assert.Equal(t, 1, task.DoneCount)
+
+		// Счётчик выполнений сохраняется при правке и сбрасывается при смене правила
+		task.Title = "Полить все цветы"
+		assert.NoError(t, store.UpdateTask(task))
+		task, err = store.GetTask(repeating)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, task.DoneCount)
+		task.RepeatCount = 5
+		assert.NoError(t, store.UpdateTask(task))
+		task, err = store.GetTask(repeating)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, task.DoneCount)
+
+		clk.Set(clk.Now().Add(time.Minute))
+		assert.NoError(t, store.CompleteTask(once, "", "", clk.Now()))
+		_, err = store.GetTask(once)
+		assert.EqualError(t, err, "задача не найдена")
+
+		archived, err := store.ArchivedTasks(db.Page{})
+		assert.NoError(t, err)
+		if assert.Len(t, archived.Tasks, 1) {
+			assert.Equal(t, once, archived.Tasks[0].ID)
+			assert.Equal(t, db.StatusDone, archived.Tasks[0].Status)
+			assert.Equal(t, "2024-01-26T09:31:00Z", archived.Tasks[0].ArchivedAt)
+		}
+
+		completions, err := store.Completions("", 50)
+		assert.NoError(t, err)
+		if assert.Len(t, completions, 2) {
+			assert.Equal(t, once, completions[0].TaskID)
+			assert.Equal(t, "Сдать отчёт", completions[0].Title)
+			assert.Equal(t, repeating, completions[1].TaskID)
+			assert.Equal(t, "20240126", completions[1].Date)
+			assert.Equal(t, "2024-01-26T09:30:00Z", completions[1].CompletedAt)
+		}
+
+		completions, err = store.Completions(repeating, 50)
+		assert.NoError(t, err)
+		assert.Len(t, completions, 1)
+		completions, err = store.Completions("", 1)
+		assert.NoError(t, err)
+		assert.Len(t, completions, 1)
+		_, err = store.Completions("x", 50)
+		assert.Error(t, err)
+	})
+}
+
+func TestStoreArchive(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		first := storeAdd(t, store, db.Task{Date: "20240126", Title: "Первая"})
+		second := storeAdd(t, store, db.Task{Date: "20240126", Title: "Вторая"})
+
+		assert.NoError(t, store.DeleteTask(first, clk.Now()))
+		assert.NoError(t, store.DeleteTask(second, clk.Now()))
+		assert.EqualError(t, store.DeleteTask(first, clk.Now()), "задача не найдена")
+
+		tasks, err := store.Tasks(db.TaskFilter{From: "20240101"}, db.Page{})
+		assert.NoError(t, err)
+		assert.Empty(t, tasks.Tasks)
+
+		archived, err := store.ArchivedTasks(db.Page{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{second, first}, taskIDs(archived.Tasks))
+		for _, task := range archived.Tasks {
+			assert.Equal(t, db.StatusDeleted, task.Status)
+		}
+
+		assert.NoError(t, store.RestoreTask(first))
+		assert.EqualError(t, store.RestoreTask(first), "задача не найдена в архиве")
+		task, err := store.GetTask(first)
+		assert.NoError(t, err)
+		assert.Empty(t, task.Status)
+		assert.Empty(t, task.ArchivedAt)
+	})
+}
+
+func TestStoreUndo(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Полить цветы", Repeat: "d 3"})
+
+		task, err := store.GetTask(id)
+		assert.NoError(t, err)
+		task.Title = "Полить кактус"
+		assert.NoError(t, store.UpdateTask(task))
+		assert.NoError(t, store.CompleteTask(id, "20240129", "20240129", clk.Now()))
+		assert.NoError(t, store.DeleteTask(id, clk.Now()))
+
+		since := clk.Now().Add(-time.Hour)
+		undone, err := store.Undo(1, since)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{id}, undone.Tasks)
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "20240129", task.Date)
+		assert.Equal(t, 1, task.DoneCount)
+
+		undone, err = store.Undo(5, since)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{id, id}, undone.Tasks)
+		task, err = store.GetTask(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "Полить цветы", task.Title)
+		assert.Equal(t, "20240126", task.Date)
+		assert.Equal(t, 0, task.DoneCount)
+		completions, err := store.Completions(id, 50)
+		assert.NoError(t, err)
+		assert.Empty(t, completions)
+
+		// Операции раньше since не отменяются и удаляются из журнала
+		assert.NoError(t, store.UpdateDate(id, "20240127"))
+		clk.Set(clk.Now().Add(2 * time.Hour))
+		undone, err = store.Undo(1, clk.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Empty(t, undone.Tasks)
+		undone, err = store.Undo(1, since)
+		assert.NoError(t, err)
+		assert.Empty(t, undone.Tasks)
+	})
+}
+
+func TestStoreConcurrency(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		const workers = 10
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id := storeAdd(t, store, db.Task{Date: "20240126", Title: fmt.Sprintf("Задача %d", i)})
+				assert.NoError(t, store.UpdateDate(id, "20240127"))
+			}(i)
+		}
+		wg.Wait()
+
+		tasks, err := store.Tasks(db.TaskFilter{From: "20240127", To: "20240127"}, db.Page{})
+		assert.NoError(t, err)
+		assert.Len(t, tasks.Tasks, workers)
+	})
+}
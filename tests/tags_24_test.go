@@ -0,0 +1,292 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"todo-server/pkg/clock"
+	"todo-server/pkg/db"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreTags(t *testing.T) {
+	testStores(t, func(t *testing.T, store db.TaskStore, clk *clock.Pinned) {
+		home := storeAdd(t, store, db.Task{Date: "20240126", Title: "Полить цветы", Tags: []string{" Дом ", "сад", "дом"}})
+		work := storeAdd(t, store, db.Task{Date: "20240127", Title: "Написать отчёт", Tags: []string{"работа"}})
+		both := storeAdd(t, store, db.Task{Date: "20240128", Title: "Отчёт о ремонте", Tags: []string{"работа", "дом"}})
+		none := storeAdd(t, store, db.Task{Date: "20240129", Title: "Отчёт без тегов"})
+		_, err := store.AddTask(&db.Task{Date: "20240129", Title: "Неверный тег", Tags: []string{"a,b"}})
+		assert.EqualError(t, err, `имя тега "a,b" не должно содержать запятую`)
+
+		task, err := store.GetTask(home)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"дом", "сад"}, task.Tags)
+		task, err = store.GetTask(none)
+		assert.NoError(t, err)
+		assert.Nil(t, task.Tags)
+
+		tagCounts := func() map[string]int {
+			tags, err := store.Tags()
+			assert.NoError(t, err)
+			res := map[string]int{}
+			var names []string
+			for _, tag := range tags {
+				res[tag.Name] = tag.Tasks
+				names = append(names, tag.Name)
+			}
+			assert.True(t, slices.IsSorted(names), names)
+			return res
+		}
+		tagID := func(name string) string {
+			tags, err := store.Tags()
+			assert.NoError(t, err)
+			for _, tag := range tags {
+				if tag.Name == name {
+					return tag.ID
+				}
+			}
+			t.Fatalf("нет тега %s", name)
+			return ""
+		}
+		assert.Equal(t, map[string]int{"дом": 2, "работа": 2, "сад": 1}, tagCounts())
+
+		// Отбор по тегам: хотя бы один или все
+		tasks := func(tags []string, all bool) []string {
+			page, err := store.Tasks(db.TaskFilter{From: "20240126", Tags: tags, AllTags: all}, db.Page{})
+			assert.NoError(t, err)
+			return taskIDs(page.Tasks)
+		}
+		assert.Equal(t, []string{home, both}, tasks([]string{"дом"}, false))
+		assert.Equal(t, []string{home, work, both}, tasks([]string{"дом", "работа"}, false))
+		assert.Equal(t, []string{both}, tasks([]string{"дом", "работа"}, true))
+		assert.Empty(t, tasks([]string{"дача"}, false))
+		page, err := store.Tasks(db.TaskFilter{From: "20240128", To: "20240128"}, db.Page{})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Tasks, 1) {
+			assert.Equal(t, []string{"дом", "работа"}, page.Tasks[0].Tags)
+		}
+
+		found, err := storeSearch(store, "отчёт tag:Работа", "20240126")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{work, both}, taskIDs(found))
+		found, err = storeSearch(store, "отчёт -tag:работа", "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{none}, taskIDs(found))
+		found, err = storeSearch(store, "tag:сад,работа", "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{home, work, both}, taskIDs(found))
+		found, err = storeSearch(store, "tag:дом tag:работа", "20240126")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{both}, taskIDs(found))
+		_, err = storeSearch(store, "tag:"+strings.Repeat("я", 51), "20240126")
+		assert.ErrorContains(t, err, "имя тега длиннее 50 символов")
+
+		// Создание, переименование, объединение и удаление тегов
+		_, err = store.AddTag("Дача")
+		assert.NoError(t, err)
+		_, err = store.AddTag("дача")
+		assert.ErrorIs(t, err, db.ErrTagExists)
+		_, err = store.AddTag(" ")
+		assert.EqualError(t, err, "не указано имя тега")
+		assert.Equal(t, 0, tagCounts()["дача"])
+
+		assert.ErrorIs(t, store.RenameTag(tagID("сад"), "Дача"), db.ErrTagExists)
+		assert.ErrorIs(t, store.RenameTag("999", "огород"), db.ErrTagNotFound)
+		assert.EqualError(t, store.RenameTag("сад", "огород"), "некорректный идентификатор тега")
+		assert.NoError(t, store.RenameTag(tagID("сад"), "Огород"))
+		assert.NoError(t, store.RenameTag(tagID("огород"), "огород"))
+		task, err = store.GetTask(home)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"дом", "огород"}, task.Tags)
+		assert.Equal(t, []string{home}, tasks([]string{"огород"}, false))
+
+		assert.NoError(t, store.MergeTags(tagID("дом"), tagID("огород")))
+		task, err = store.GetTask(home)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"огород"}, task.Tags)
+		task, err = store.GetTask(both)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"огород", "работа"}, task.Tags)
+		assert.Equal(t, map[string]int{"дача": 0, "огород": 2, "работа": 2}, tagCounts())
+		assert.ErrorIs(t, store.MergeTags("999", tagID("огород")), db.ErrTagNotFound)
+		assert.EqualError(t, store.MergeTags(tagID("огород"), tagID("огород")), "тег нельзя объединить с самим собой")
+
+		assert.NoError(t, store.DeleteTag(tagID("работа")))
+		assert.ErrorIs(t, store.DeleteTag("999"), db.ErrTagNotFound)
+		task, err = store.GetTask(work)
+		assert.NoError(t, err)
+		assert.Nil(t, task.Tags)
+		assert.Equal(t, map[string]int{"дача": 0, "огород": 2}, tagCounts())
+
+		// Архивные задачи не считаются, теги меняются при обновлении и восстанавливаются отменой
+		assert.NoError(t, store.DeleteTask(home, clk.Now()))
+		assert.Equal(t, 1, tagCounts()["огород"])
+
+		task, err = store.GetTask(both)
+		assert.NoError(t, err)
+		task.Tags = []string{"дача", "ремонт"}
+		clk.Set(clk.Now().Add(time.Minute))
+		assert.NoError(t, store.UpdateTask(task))
+		task, err = store.GetTask(both)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"дача", "ремонт"}, task.Tags)
+		assert.NoError(t, store.DeleteTag(tagID("огород")))
+
+		undone, err := store.Undo(1, clk.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{both}, undone.Tasks)
+		task, err = store.GetTask(both)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"огород"}, task.Tags)
+		assert.Equal(t, map[string]int{"дача": 0, "огород": 1, "ремонт": 0}, tagCounts())
+	})
+}
+
+func TestTags(t *testing.T) {
+	if !Search {
+		return
+	}
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	add := func(title string, tags ...string) string {
+		ret, err := postJSON("api/task", map[string]any{"date": date, "title": title, "tags": tags}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+		return ret["id"].(string)
+	}
+	home := add("Теги дом", "Тест-дом", "тест-сад")
+	work := add("Теги работа", "тест-работа")
+	both := add("Теги ремонт", "тест-дом", "тест-работа")
+	ids := []string{home, work, both}
+
+	type tag struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Tasks int    `json:"tasks"`
+	}
+	tags := func() map[string]tag {
+		body, err := requestJSON("api/tags", nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp struct {
+			Tags []tag `json:"tags"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		res := map[string]tag{}
+		for _, tag := range resp.Tags {
+			if strings.HasPrefix(tag.Name, "тест-") {
+				res[tag.Name] = tag
+			}
+		}
+		return res
+	}
+	defer func() {
+		for _, id := range ids {
+			_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+		for _, tag := range tags() {
+			_, err := postJSON("api/tag?id="+tag.ID, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+	}()
+
+	task, err := postJSON("api/task?id="+home, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"тест-дом", "тест-сад"}, task["tags"])
+	list := tags()
+	assert.Equal(t, 2, list["тест-дом"].Tasks)
+	assert.Equal(t, 1, list["тест-сад"].Tasks)
+
+	type tasksResp struct {
+		Tasks []map[string]any `json:"tasks"`
+		Error string           `json:"error"`
+	}
+	get := func(params url.Values) tasksResp {
+		body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp tasksResp
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		return resp
+	}
+	ours := func(params url.Values) []string {
+		resp := get(params)
+		assert.Empty(t, resp.Error, params.Encode())
+		var res []string
+		for _, task := range resp.Tasks {
+			if id := task["id"].(string); slices.Contains(ids, id) {
+				res = append(res, id)
+			}
+		}
+		return res
+	}
+	assert.Equal(t, []string{home, both}, ours(url.Values{"tags": {"тест-дом"}}))
+	assert.Equal(t, []string{home, work, both}, ours(url.Values{"tags": {"Тест-сад,тест-работа"}}))
+	assert.Equal(t, []string{both}, ours(url.Values{"tags": {"тест-дом,тест-работа"}, "tags_mode": {"all"}}))
+	assert.Equal(t, []string{both}, ours(url.Values{"search": {"теги"}, "tags": {"тест-дом,тест-работа"}, "tags_mode": {"all"}}))
+	assert.Equal(t, []string{work}, ours(url.Values{"search": {"теги -tag:тест-дом"}}))
+	assert.Equal(t, []string{home, work, both}, ours(url.Values{"search": {time.Now().AddDate(0, 0, 2).Format(`02.01.2006`)},
+		"tags": {"тест-сад,тест-работа"}}))
+
+	// Создание и переименование тегов
+	ret, err := postJSON("api/tag", map[string]any{"name": "Тест-дача"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["id"])
+	ret, err = postJSON("api/tag", map[string]any{"name": "тест-дача"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "тег с таким именем уже существует", ret["error"])
+	ret, err = postJSON("api/tag", map[string]any{"name": ""}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "не указано имя тега", ret["error"])
+
+	list = tags()
+	ret, err = postJSON("api/tag", map[string]any{"id": list["тест-сад"].ID, "name": "тест-дача"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, "тег с таким именем уже существует", ret["error"])
+	ret, err = postJSON("api/tag", map[string]any{"id": list["тест-сад"].ID, "name": "тест-огород"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task, err = postJSON("api/task?id="+home, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"тест-дом", "тест-огород"}, task["tags"])
+
+	// Объединение и удаление
+	list = tags()
+	ret, err = postJSON("api/tag/merge?id="+list["тест-дом"].ID+"&into="+list["тест-работа"].ID, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	list = tags()
+	assert.NotContains(t, list, "тест-дом")
+	assert.Equal(t, 3, list["тест-работа"].Tasks)
+	task, err = postJSON("api/task?id="+both, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"тест-работа"}, task["tags"])
+
+	ret, err = postJSON("api/tag?id="+list["тест-огород"].ID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/tag?id="+list["тест-огород"].ID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, "тег не найден", ret["error"])
+	task, err = postJSON("api/task?id="+home, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"тест-работа"}, task["tags"])
+
+	ret, err = postJSON("api/task", map[string]any{"date": date, "title": "Неверный тег", "tags": []string{"a,b"}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, `имя тега "a,b" не должно содержать запятую`, ret["error"])
+	for params, expected := range map[string]string{
+		"tags=тест-дом&tags_mode=some":          "tags_mode должен быть any или all",
+		"tags=тест-дом,,тест-сад":               "не указано имя тега",
+		"archived=true&tags=тест-дом":           "параметры sort, priority, tags и project не применяются к архиву",
+		"search=tag:" + strings.Repeat("я", 51): "ошибка в запросе на позиции 1 (tag:" + strings.Repeat("я", 51) + "): имя тега длиннее 50 символов",
+	} {
+		values, err := url.ParseQuery(params)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, get(values).Error, params)
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
@@ -73,3 +74,345 @@ func TestDelTask(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, ret)
 }
+
+func TestDoneWeekInterval(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	weekday := int(now.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Спринт-ревью раз в две недели",
+		repeat: fmt.Sprintf("w %d 2", weekday),
+	})
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 14).Format(`20060102`), task.Date)
+
+	// Задачу выполнили с опозданием: следующая дата считается от начала серии,
+	// пока текущая дата задачи попадает в сетку недель
+	_, err = db.Exec(`UPDATE scheduler SET date = ?, repeat_start = ? WHERE id = ?`,
+		now.AddDate(0, 0, -7).Format(`20060102`), now.AddDate(0, 0, -21).Format(`20060102`), id)
+	assert.NoError(t, err)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 7).Format(`20060102`), task.Date)
+	assert.Equal(t, now.AddDate(0, 0, -21).Format(`20060102`), task.RepeatStart)
+
+	// Дату задачи перенесли вне сетки: серия отсчитывается от новой даты
+	_, err = db.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, now.Format(`20060102`), id)
+	assert.NoError(t, err)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 14).Format(`20060102`), task.Date)
+	assert.Equal(t, now.Format(`20060102`), task.RepeatStart)
+}
+
+func TestUpdateRepeatStart(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+	get := func(id string) Task {
+		var task Task
+		assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+		return task
+	}
+	update := func(id string, fields map[string]any) {
+		fields["id"] = id
+		fields["title"] = "Полить цветы"
+		ret, err := postJSON("api/task", fields, http.MethodPut)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	done := func(id string) {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	// Дату "d 7" сдвинули на день: серия начинается с новой даты, даже если клиент
+	// (как веб-интерфейс) прислал прежнее начало серии
+	id := addTask(t, task{date: day(0), title: "Полить цветы", repeat: "d 7"})
+	update(id, map[string]any{"date": day(1), "repeat": "d 7", "repeat_start": day(0)})
+	assert.Equal(t, day(1), get(id).RepeatStart)
+	done(id)
+	assert.Equal(t, day(8), get(id).Date)
+	assert.Equal(t, day(1), get(id).RepeatStart)
+
+	// Дату перенесли в пределах сетки серии - начало серии сохраняется
+	id = addTask(t, task{date: day(0), title: "Полить цветы", repeat: "d 7"})
+	_, err := db.Exec(`UPDATE scheduler SET repeat_start = ? WHERE id = ?`, day(-7), id)
+	assert.NoError(t, err)
+	update(id, map[string]any{"date": day(7), "repeat": "d 7"})
+	assert.Equal(t, day(-7), get(id).RepeatStart)
+
+	// Правило изменили - серия начинается с даты задачи
+	update(id, map[string]any{"date": day(7), "repeat": "d 3", "repeat_start": day(-7)})
+	assert.Equal(t, day(7), get(id).RepeatStart)
+	done(id)
+	assert.Equal(t, day(10), get(id).Date)
+
+	// Новое начало серии, переданное клиентом, сохраняется
+	update(id, map[string]any{"date": day(10), "repeat": "d 3", "repeat_start": day(4)})
+	assert.Equal(t, day(4), get(id).RepeatStart)
+}
+
+func TestDoneEndConditions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	for _, v := range []map[string]any{
+		{"date": today, "title": "Без правила", "repeat_count": 3},
+		{"date": today, "title": "Без правила", "repeat_until": "20990101"},
+		{"date": today, "title": "Отрицательный счётчик", "repeat": "d 1", "repeat_count": -1},
+		{"date": today, "title": "Неверная дата", "repeat": "d 1", "repeat_until": "01.01.2099"},
+	} {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		e, ok := m["error"]
+		assert.False(t, !ok || len(fmt.Sprint(e)) == 0, "Ожидается ошибка для задачи %v", v)
+	}
+
+	done := func(id string) {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	addWithEnd := func(values map[string]any) string {
+		m, err := postJSON("api/task", values, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, m["id"])
+		return fmt.Sprint(m["id"])
+	}
+
+	// Два выполнения
+	id := addWithEnd(map[string]any{
+		"date": today, "title": "Два раза", "repeat": "d 1", "repeat_count": 2,
+	})
+	done(id)
+	var task Task
+	err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+	assert.Equal(t, 1, task.DoneCount)
+	done(id)
+	notFoundTask(t, id)
+
+	// Выполнения по прежнему правилу не засчитываются после его изменения
+	id = addWithEnd(map[string]any{
+		"date": today, "title": "Три раза", "repeat": "d 1", "repeat_count": 3,
+	})
+	done(id)
+	done(id)
+	update := func(values map[string]any) {
+		ret, err := postJSON("api/task", values, http.MethodPut)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+	}
+	update(map[string]any{"id": id, "date": task.Date, "title": "Три раза с комментарием",
+		"repeat": "d 1", "repeat_count": 3})
+	assert.Equal(t, 2, task.DoneCount)
+	update(map[string]any{"id": id, "date": task.Date, "title": "Пять раз", "repeat": "d 2", "repeat_count": 5})
+	assert.Equal(t, 0, task.DoneCount)
+	done(id)
+	done(id)
+	done(id)
+	update(map[string]any{"id": id, "date": task.Date, "title": "Пять раз", "repeat": "d 2", "repeat_count": 4})
+	assert.Equal(t, 0, task.DoneCount)
+	for range 3 {
+		done(id)
+	}
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, task.DoneCount)
+	done(id)
+	notFoundTask(t, id)
+
+	// Следующая дата позже repeat_until
+	id = addWithEnd(map[string]any{
+		"date": today, "title": "До даты", "repeat": "d 3",
+		"repeat_until": now.AddDate(0, 0, 2).Format(`20060102`),
+	})
+	done(id)
+	notFoundTask(t, id)
+
+	// COUNT из RRULE сохраняется как repeat_count
+	id = addWithEnd(map[string]any{
+		"date": today, "title": "RRULE COUNT", "repeat": "RRULE:FREQ=DAILY;COUNT=2",
+	})
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, task.RepeatCount)
+	done(id)
+	done(id)
+	notFoundTask(t, id)
+}
+
+func TestSkipAndExdates(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	single := addTask(t, task{date: day(0), title: "Разовая задача"})
+	id := addTask(t, task{date: day(0), title: "Полить цветы", repeat: "d 1"})
+
+	for _, path := range []string{
+		"api/task/exdate?id=" + id,
+		"api/task/exdate?id=" + id + "&date=ooops",
+		"api/task/exdate?id=" + single + "&date=" + day(1),
+		"api/task/skip?id=" + single,
+		"api/task/skip",
+	} {
+		ret, err := postJSON(path, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], path)
+	}
+
+	var task Task
+	ret, err := postJSON("api/task/exdate?id="+id+"&date="+day(2), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(1), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(1)+","+day(2), task.Exdates)
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(2), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	// Выполнение пропускает дату-исключение
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(2), task.Date)
+	assert.Equal(t, 1, task.DoneCount)
+
+	// Пропуск переносит дату, но не считается выполнением
+	ret, err = postJSON("api/task/skip?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(3), task.Date)
+	assert.Equal(t, 1, task.DoneCount)
+
+	// Исключение текущей даты задачи переносит её на следующее повторение
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(3), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(4), task.Date)
+	assert.Equal(t, day(1)+","+day(3), task.Exdates)
+	assert.Equal(t, 1, task.DoneCount)
+
+	// Отмена возвращает и дату, и список исключений
+	ret, err = postJSON("api/undo", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{id}, ret["undone"])
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(3), task.Date)
+	assert.Equal(t, day(1), task.Exdates)
+
+	// Пропуск последнего повторения завершает серию: задача уходит в архив как выполненная
+	m, err := postJSON("api/task", map[string]any{
+		"date": day(0), "title": "Полить цветы", "repeat": "d 1", "repeat_until": day(1),
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id = fmt.Sprint(m["id"])
+	for range 2 {
+		ret, err = postJSON("api/task/skip?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", task.Status)
+	assert.Equal(t, day(1), task.Date)
+}
+
+func TestDoneFromCompletion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	for _, v := range []map[string]any{
+		{"date": day(0), "title": "Без правила", "repeat_mode": "completion"},
+		{"date": day(0), "title": "Неизвестный режим", "repeat": "d 3", "repeat_mode": "ooops"},
+	} {
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		e, ok := m["error"]
+		assert.False(t, !ok || len(fmt.Sprint(e)) == 0, "Ожидается ошибка для задачи %v", v)
+	}
+
+	m, err := postJSON("api/task", map[string]any{
+		"date": day(0), "title": "Полить цветы", "repeat": "d 3", "repeat_mode": "completion",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(m["id"])
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var got map[string]any
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, "completion", got["repeat_mode"])
+
+	// Задача просрочена на 5 дней: по расписанию следующая дата была бы завтра,
+	// а от выполнения - через 3 дня
+	_, err = db.Exec(`UPDATE scheduler SET date = ?, repeat_start = ? WHERE id = ?`, day(-5), day(-5), id)
+	assert.NoError(t, err)
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(3), task.Date)
+	assert.Equal(t, day(0), task.RepeatStart)
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	date := now.AddDate(0, 0, 400)
+
+	for _, v := range []map[string]any{
+		{"start_time": "25:00"},
+		{"start_time": "10-00"},
+		{"duration": 15},
+		{"start_time": "10:00", "duration": -5},
+		{"start_time": "10:00", "duration": 24*60 + 1},
+	} {
+		v["date"] = date.Format(`20060102`)
+		v["title"] = "Задача со временем"
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		e, ok := m["error"]
+		assert.False(t, !ok || len(fmt.Sprint(e)) == 0, "Ожидается ошибка для задачи %v", v)
+	}
+
+	var ids []string
+	for _, v := range []map[string]any{
+		{"title": "Ужин", "start_time": "18:00", "duration": 90},
+		{"title": "Весь день"},
+		{"title": "Планёрка", "start_time": "9:05", "duration": 15},
+	} {
+		v["date"] = date.Format(`20060102`)
+		m, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, m["error"])
+		ids = append(ids, fmt.Sprint(m["id"]))
+	}
+
+	var task Task
+	err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, ids[2])
+	assert.NoError(t, err)
+	assert.Equal(t, "09:05", task.StartTime)
+	assert.Equal(t, 15, task.Duration)
+
+	body, err := requestJSON("api/tasks?search="+date.Format(`02.01.2006`), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var list struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &list))
+	var titles []string
+	for _, task := range list.Tasks {
+		titles = append(titles, fmt.Sprint(task["title"]))
+	}
+	assert.Equal(t, []string{"Весь день", "Планёрка", "Ужин"}, titles)
+
+	for _, id := range ids {
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":       now.Format(`20060102`),
+		"title":      "Стендап",
+		"repeat":     "d 1",
+		"start_time": "10:00",
+		"duration":   15,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(m["id"])
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+	assert.Equal(t, "10:00", task.StartTime)
+	assert.Equal(t, 15, task.Duration)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
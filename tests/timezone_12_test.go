@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestInZone(t *testing.T, method, apipath, zone string, values map[string]any) map[string]any {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Timezone", zone)
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m
+}
+
+func TestTimezone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// Разница между поясами 25 часов, поэтому "сегодня" в них всегда разное
+	const east, west = "Pacific/Kiritimati", "Pacific/Pago_Pago"
+	eastLoc, err := time.LoadLocation(east)
+	assert.NoError(t, err)
+	westLoc, err := time.LoadLocation(west)
+	assert.NoError(t, err)
+	eastToday := time.Now().In(eastLoc).Format(`20060102`)
+	westToday := time.Now().In(westLoc).Format(`20060102`)
+
+	m := requestInZone(t, http.MethodPost, "api/task", "Mars/Olympus", map[string]any{"title": "Марс"})
+	assert.NotEmpty(t, m["error"])
+
+	var ids []string
+	check := func(zone string, values map[string]any, want string) {
+		m := requestInZone(t, http.MethodPost, "api/task", zone, values)
+		assert.Empty(t, m["error"])
+		id := fmt.Sprint(m["id"])
+		ids = append(ids, id)
+
+		var task Task
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, want, task.Date, "%s %v", zone, values)
+	}
+	check(east, map[string]any{"title": "Без даты"}, eastToday)
+	check(west, map[string]any{"title": "Без даты"}, westToday)
+	// Восточная дата для западного пояса ещё в будущем, западная для восточного - уже в прошлом
+	check(west, map[string]any{"title": "Завтра", "date": eastToday}, eastToday)
+	check(east, map[string]any{"title": "Вчера", "date": westToday}, eastToday)
+
+	var list struct {
+		Tasks []map[string]any `json:"tasks"`
+	}
+	for zone, want := range map[string]bool{east: false, west: true} {
+		body, err := json.Marshal(requestInZone(t, http.MethodGet, "api/tasks", zone, nil))
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &list))
+		found := false
+		for _, task := range list.Tasks {
+			if task["id"] == ids[1] {
+				found = true
+			}
+		}
+		assert.Equal(t, want, found, "Задача на %s в списке для %s", westToday, zone)
+	}
+
+	for _, id := range ids {
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndo(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	id := addTask(t, task{
+		date:   today,
+		title:  "Полить цветы",
+		repeat: "d 3",
+	})
+
+	ret, err := postJSON("api/task", map[string]any{
+		"id":     id,
+		"date":   today,
+		"title":  "Полить кактус",
+		"repeat": "d 3",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	for _, n := range []string{"0", "101", "x"} {
+		ret, err = postJSON("api/undo?n="+n, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"])
+	}
+
+	// Отменяем удаление
+	ret, err = postJSON("api/undo", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{id}, ret["undone"])
+
+	var task Task
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Empty(t, task.Status)
+	assert.Equal(t, "Полить кактус", task.Title)
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), task.Date)
+	assert.Equal(t, 1, task.DoneCount)
+	assert.Len(t, getCompletions(t, id), 1)
+
+	// Отменяем выполнение и изменение
+	ret, err = postJSON("api/undo?n=2", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{id, id}, ret["undone"])
+
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Полить цветы", task.Title)
+	assert.Equal(t, today, task.Date)
+	assert.Equal(t, 0, task.DoneCount)
+	assert.Empty(t, getCompletions(t, id))
+
+	if Debug {
+		// Операции старше окна отмены не отменяются
+		ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		ret, err = postJSON("api/debug/now?now="+now.Add(2*time.Hour).Format(`20060102T150405`), nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, true, ret["pinned"])
+		ret, err = postJSON("api/undo", nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"])
+		_, err = postJSON("api/debug/now", nil, http.MethodDelete)
+		assert.NoError(t, err)
+
+		ret, err = postJSON("api/undo", nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"])
+		notFoundTask(t, id)
+	}
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}